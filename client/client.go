@@ -0,0 +1,353 @@
+// Package client talks to the PublicAPI of a node, so wallets and other programs
+// don't have to deal with grpc and proto types themselves.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPoolSize     = 4
+	defaultRetries      = 3
+	defaultBackoff      = 100 * time.Millisecond
+	maxBackoff          = 5 * time.Second
+	defaultPollInterval = time.Second
+)
+
+type Option func(*config)
+
+type config struct {
+	token        string
+	tls          *tls.Config
+	poolSize     int
+	retries      int
+	backoff      time.Duration
+	pollInterval time.Duration
+}
+
+// WithToken sets the token the node wants in ServerConfig.PublicToken.
+func WithToken(token string) Option {
+	return func(c *config) {
+		c.token = token
+	}
+}
+
+// WithTLS connects over TLS, for a node with ServerConfig.PublicTLS. Set Certificates when the node wants a client certificate.
+func WithTLS(cfg *tls.Config) Option {
+	return func(c *config) {
+		c.tls = cfg
+	}
+}
+
+// WithPoolSize sets the number of connections calls are spread over.
+func WithPoolSize(size int) Option {
+	return func(c *config) {
+		c.poolSize = size
+	}
+}
+
+// WithRetries sets how many times a call is retried when the node is unavailable or
+// rate limits us. The wait between attempts starts at backoff and doubles each time.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *config) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithPollInterval sets how often WaitForConfirmation asks the node about a tx.
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *config) {
+		c.pollInterval = interval
+	}
+}
+
+// A Client is safe to use from multiple goroutines. Create one and reuse it,
+// every call picks the next connection of the pool.
+type Client struct {
+	config
+	conns []*grpc.ClientConn
+	apis  []proto.PublicAPIClient
+	next  atomic.Uint64
+}
+
+// Dial connects to the PublicAPI of the node at addr (its ServerConfig.PublicListenAddr).
+func Dial(addr string, opts ...Option) (*Client, error) {
+	c := &Client{
+		config: config{
+			poolSize:     defaultPoolSize,
+			retries:      defaultRetries,
+			backoff:      defaultBackoff,
+			pollInterval: defaultPollInterval,
+		},
+	}
+	for _, opt := range opts {
+		opt(&c.config)
+	}
+	if c.poolSize < 1 {
+		return nil, fmt.Errorf("pool size must be at least 1, got (%d)", c.poolSize)
+	}
+
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if c.tls != nil {
+		dialOpts = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(c.tls))}
+	}
+	if c.token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(TokenCredentials(c.token)))
+	}
+	for i := 0; i < c.poolSize; i++ {
+		conn, err := grpc.Dial(addr, dialOpts...)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.conns = append(c.conns, conn)
+		c.apis = append(c.apis, proto.NewPublicAPIClient(conn))
+	}
+	return c, nil
+}
+
+func (c *Client) Close() error {
+	var err error
+	for _, conn := range c.conns {
+		if cerr := conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (c *Client) api() proto.PublicAPIClient {
+	return c.apis[c.next.Add(1)%uint64(len(c.apis))]
+}
+
+// retry calls fn until it succeeds, fails with an error worth giving up on, or we run out of retries.
+func (c *Client) retry(ctx context.Context, fn func(api proto.PublicAPIClient) error) error {
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := fn(c.api())
+		if err == nil || attempt >= c.retries || !isRetryable(err) {
+			return err
+		}
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// SubmitTx sends a signed tx to the node and returns its hash. Submitting the same tx twice is fine,
+// so it gets retried like every other call.
+func (c *Client) SubmitTx(ctx context.Context, tx *proto.Transaction) ([]byte, error) {
+	err := c.retry(ctx, func(api proto.PublicAPIClient) error {
+		_, err := api.SubmitTransaction(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.HashTransaction(tx), nil
+}
+
+func (c *Client) GetTransaction(ctx context.Context, hash []byte) (txStatus *proto.TransactionStatus, err error) {
+	err = c.retry(ctx, func(api proto.PublicAPIClient) error {
+		txStatus, err = api.GetTransaction(ctx, &proto.GetTransactionRequest{Hash: hash})
+		return err
+	})
+	return txStatus, err
+}
+
+// WaitForConfirmation blocks until the tx is in a block with at least confirmations
+// blocks on top of it (counting its own), or until ctx is done.
+func (c *Client) WaitForConfirmation(ctx context.Context, hash []byte, confirmations int) (*proto.TransactionStatus, error) {
+	for {
+		txStatus, err := c.GetTransaction(ctx, hash)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err() // Ran out of time during the call instead of in between.
+			}
+			return nil, err
+		}
+		if txStatus.Status == proto.TxStatus_TX_CONFIRMED && int(txStatus.Confirmations) >= confirmations {
+			return txStatus, nil
+		}
+		if err := sleep(ctx, c.pollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) GetBlock(ctx context.Context, hash []byte) (block *proto.Block, err error) {
+	err = c.retry(ctx, func(api proto.PublicAPIClient) error {
+		block, err = api.GetBlock(ctx, &proto.GetBlockRequest{Hash: hash})
+		return err
+	})
+	return block, err
+}
+
+func (c *Client) GetBlockByHeight(ctx context.Context, height int) (block *proto.Block, err error) {
+	err = c.retry(ctx, func(api proto.PublicAPIClient) error {
+		block, err = api.GetBlock(ctx, &proto.GetBlockRequest{Height: int32(height)})
+		return err
+	})
+	return block, err
+}
+
+// GetAnchor returns where the data with the content hash (see types.HashData) was first anchored on chain.
+func (c *Client) GetAnchor(ctx context.Context, contentHash []byte) (anchor *proto.Anchor, err error) {
+	err = c.retry(ctx, func(api proto.PublicAPIClient) error {
+		anchor, err = api.GetAnchor(ctx, &proto.AnchorRequest{ContentHash: contentHash})
+		return err
+	})
+	return anchor, err
+}
+
+// GetBalance returns the balance of the address for each asset it owns, keyed by the
+// hex encoded asset ID. The native coin is under "".
+func (c *Client) GetBalance(ctx context.Context, address []byte) (map[string]uint64, error) {
+	var resp *proto.Balance
+	err := c.retry(ctx, func(api proto.PublicAPIClient) (err error) {
+		resp, err = api.GetBalance(ctx, &proto.BalanceRequest{Address: address})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	balance := make(map[string]uint64, len(resp.Assets))
+	for _, asset := range resp.Assets {
+		balance[hex.EncodeToString(asset.Asset)] = asset.Amount
+	}
+	return balance, nil
+}
+
+func (c *Client) GetUTXOs(ctx context.Context, address []byte) ([]*proto.UTXO, error) {
+	var resp *proto.UTXOList
+	err := c.retry(ctx, func(api proto.PublicAPIClient) (err error) {
+		resp, err = api.GetUTXOs(ctx, &proto.UTXORequest{Address: address})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Utxos, nil
+}
+
+func (c *Client) GetTxProof(ctx context.Context, txHash []byte, blockHash []byte) (proof *proto.TxProof, err error) {
+	err = c.retry(ctx, func(api proto.PublicAPIClient) error {
+		proof, err = api.GetTxProof(ctx, &proto.TxProofRequest{TxHash: txHash, BlockHash: blockHash})
+		return err
+	})
+	return proof, err
+}
+
+func (c *Client) GetAddressHistory(ctx context.Context, address []byte, offset int, limit int) (history *proto.AddressHistory, err error) {
+	err = c.retry(ctx, func(api proto.PublicAPIClient) error {
+		history, err = api.GetAddressHistory(ctx, &proto.AddressHistoryRequest{
+			Address: address,
+			Offset:  int32(offset),
+			Limit:   int32(limit),
+		})
+		return err
+	})
+	return history, err
+}
+
+func (c *Client) GetPeers(ctx context.Context) ([]*proto.PeerInfo, error) {
+	var resp *proto.PeerList
+	err := c.retry(ctx, func(api proto.PublicAPIClient) (err error) {
+		resp, err = api.GetPeers(ctx, &proto.PeersRequest{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Peers, nil
+}
+
+// Subscribe sends the events of the given topics on the returned channel until ctx is done,
+// then it closes the channel. No topics means every topic. When the stream breaks we
+// subscribe again with backoff, events sent in between are lost.
+func (c *Client) Subscribe(ctx context.Context, topics []string, addresses [][]byte) (<-chan *proto.Event, error) {
+	req := &proto.SubscribeRequest{
+		Topics:    topics,
+		Addresses: addresses,
+	}
+	var stream proto.PublicAPI_SubscribeClient
+	err := c.retry(ctx, func(api proto.PublicAPIClient) (err error) {
+		stream, err = api.Subscribe(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *proto.Event)
+	go func() {
+		defer close(events)
+		backoff := c.backoff
+		for {
+			e, err := stream.Recv()
+			if err == nil {
+				backoff = c.backoff
+				select {
+				case events <- e:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+			if sleep(ctx, backoff) != nil {
+				return
+			}
+			backoff = min(backoff*2, maxBackoff)
+			if s, err := c.api().Subscribe(ctx, req); err == nil {
+				stream = s
+			}
+		}
+	}()
+	return events, nil
+}
+
+// TokenCredentials sends a token with every call, as "authorization: Bearer <token>".
+// Use it with grpc.WithPerRPCCredentials to talk to a node without this package.
+type TokenCredentials string
+
+func (t TokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t TokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
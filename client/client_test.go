@@ -0,0 +1,187 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/node"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	"github.com/Fito305/blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func startPublicAPI(t *testing.T, token string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	n := node.NewNode(node.ServerConfig{
+		ListenAddr:       "127.0.0.1:0",
+		PublicListenAddr: addr,
+		PublicToken:      token,
+	})
+	go n.Start("127.0.0.1:0", nil)
+	waitForListener(t, addr)
+	return addr
+}
+
+func waitForListener(t *testing.T, addr string) {
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func randomTx() *proto.Transaction {
+	privKey := crypto.GeneratePrivateKey()
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{{
+			PrevTxHash: util.RandomHash(),
+			PublicKey:  privKey.Public().Bytes(),
+		}},
+		Outputs: []*proto.TxOutput{{
+			Amount:  10,
+			Address: privKey.Public().Address().Bytes(),
+		}},
+	}
+	tx.Inputs[0].Signature = types.SignTransaction(privKey, tx).Bytes()
+	return tx
+}
+
+func TestClientSubmitTransaction(t *testing.T) {
+	var (
+		addr   = startPublicAPI(t, "secret")
+		ctx    = context.Background()
+		tx     = randomTx()
+		txHash = types.HashTransaction(tx)
+	)
+
+	c, err := Dial(addr)
+	require.Nil(t, err)
+	defer c.Close()
+	_, err = c.GetTransaction(ctx, txHash)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	c, err = Dial(addr, WithToken("secret"))
+	require.Nil(t, err)
+	defer c.Close()
+
+	// The tx spends an output that doesn't exist.
+	_, err = c.SubmitTx(ctx, tx)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	status, err := c.GetTransaction(ctx, txHash)
+	require.Nil(t, err)
+	assert.Equal(t, proto.TxStatus_TX_UNKNOWN, status.Status)
+
+	history, err := c.GetAddressHistory(ctx, crypto.GeneratePrivateKey().Public().Address().Bytes(), 0, 10)
+	assert.Nil(t, history)
+	assert.NotNil(t, err)
+}
+
+func TestClientQueries(t *testing.T) {
+	var (
+		addr = startPublicAPI(t, "")
+		ctx  = context.Background()
+	)
+	c, err := Dial(addr, WithPollInterval(10*time.Millisecond))
+	require.Nil(t, err)
+	defer c.Close()
+
+	genesis, err := c.GetBlockByHeight(ctx, 0)
+	require.Nil(t, err)
+	block, err := c.GetBlock(ctx, types.HashBlock(genesis))
+	require.Nil(t, err)
+	assert.Equal(t, types.HashBlock(genesis), types.HashBlock(block))
+	_, err = c.GetBlockByHeight(ctx, 1)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	outputs := genesis.Transactions[0].Outputs
+	anchor, err := c.GetAnchor(ctx, types.HashData(outputs[len(outputs)-1].Data))
+	require.Nil(t, err)
+	assert.Equal(t, types.HashBlock(genesis), anchor.BlockHash)
+	_, err = c.GetAnchor(ctx, types.HashData([]byte("nothing")))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	var (
+		genesisTx = genesis.Transactions[0]
+		owner     = genesisTx.Outputs[0].Address
+	)
+	balance, err := c.GetBalance(ctx, owner)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]uint64{"": genesisTx.Outputs[0].Amount}, balance)
+
+	utxos, err := c.GetUTXOs(ctx, owner)
+	require.Nil(t, err)
+	require.Len(t, utxos, 1)
+	assert.Equal(t, types.HashTransaction(genesisTx), utxos[0].Hash)
+
+	txStatus, err := c.WaitForConfirmation(ctx, types.HashTransaction(genesisTx), 1)
+	require.Nil(t, err)
+	assert.Equal(t, int32(0), txStatus.Height)
+
+	// There is no second block on top of it.
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = c.WaitForConfirmation(timeout, types.HashTransaction(genesisTx), 2)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestClientRetriesWhenRateLimited(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	n := node.NewNode(node.ServerConfig{
+		ListenAddr:       "127.0.0.1:0",
+		PublicListenAddr: addr,
+		PublicRateLimit:  node.RateLimit{PerSecond: 20, Burst: 1},
+	})
+	go n.Start("127.0.0.1:0", nil)
+	waitForListener(t, addr)
+
+	noRetries, err := Dial(addr, WithRetries(0, 0))
+	require.Nil(t, err)
+	defer noRetries.Close()
+	c, err := Dial(addr, WithRetries(5, 20*time.Millisecond))
+	require.Nil(t, err)
+	defer c.Close()
+
+	ctx := context.Background()
+	_, err = noRetries.GetBlockByHeight(ctx, 0)
+	require.Nil(t, err)
+	_, err = noRetries.GetBlockByHeight(ctx, 0)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	for i := 0; i < 3; i++ {
+		_, err := c.GetBlockByHeight(ctx, 0)
+		assert.Nil(t, err)
+	}
+}
+
+func TestClientSubscribeClosesWithContext(t *testing.T) {
+	addr := startPublicAPI(t, "")
+	c, err := Dial(addr)
+	require.Nil(t, err)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := c.Subscribe(ctx, []string{node.TopicBlock}, nil)
+	require.Nil(t, err)
+	cancel()
+	for range events {
+	}
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+)
+
+// NewTransfer builds a tx that sends amount of asset (nil for the native coin) from the address of
+// privKey to the address to, and signs it. It spends the utxos in the order they are given until
+// it has enough, and sends what is left back to the address of privKey.
+func NewTransfer(privKey *crypto.PrivateKey, utxos []*proto.UTXO, to []byte, amount uint64, asset []byte) (*proto.Transaction, error) {
+	if amount == 0 {
+		return nil, fmt.Errorf("cannot transfer nothing")
+	}
+	var (
+		from  = privKey.Public().Address().Bytes()
+		tx    = &proto.Transaction{Version: 1}
+		total uint64
+	)
+	for _, utxo := range utxos {
+		if total >= amount {
+			break
+		}
+		if !bytes.Equal(utxo.Address, from) || !bytes.Equal(utxo.Asset, asset) {
+			continue
+		}
+		tx.Inputs = append(tx.Inputs, &proto.TxInput{
+			PrevTxHash:   utxo.Hash,
+			PrevOutIndex: utxo.OutIndex,
+			PublicKey:    privKey.Public().Bytes(),
+		})
+		total += utxo.Amount
+	}
+	if total < amount {
+		return nil, fmt.Errorf("insufficient balance got (%d) need (%d)", total, amount)
+	}
+
+	tx.Outputs = append(tx.Outputs, &proto.TxOutput{
+		Amount:  amount,
+		Address: to,
+		Asset:   asset,
+	})
+	if change := total - amount; change > 0 {
+		tx.Outputs = append(tx.Outputs, &proto.TxOutput{
+			Amount:  change,
+			Address: from,
+			Asset:   asset,
+		})
+	}
+	SignInputs(privKey, tx)
+	return tx, nil
+}
+
+// SignInputs signs every input of the tx with privKey. Call it after the last change to the tx,
+// every signature covers the whole tx.
+func SignInputs(privKey *crypto.PrivateKey, tx *proto.Transaction) {
+	sig := types.SignTransaction(privKey, tx).Bytes()
+	for _, input := range tx.Inputs {
+		input.Signature = sig
+	}
+}
+
+// Transfer fetches the utxos of privKey from the node, builds the tx with NewTransfer and submits it.
+// It returns the hash of the tx, wait for it with WaitForConfirmation.
+func (c *Client) Transfer(ctx context.Context, privKey *crypto.PrivateKey, to []byte, amount uint64, asset []byte) ([]byte, error) {
+	utxos, err := c.GetUTXOs(ctx, privKey.Public().Address().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	tx, err := NewTransfer(privKey, utxos, to, amount, asset)
+	if err != nil {
+		return nil, err
+	}
+	return c.SubmitTx(ctx, tx)
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	"github.com/Fito305/blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTransfer(t *testing.T) {
+	var (
+		privKey = crypto.GeneratePrivateKey()
+		from    = privKey.Public().Address().Bytes()
+		to      = crypto.GeneratePrivateKey().Public().Address().Bytes()
+		asset   = util.RandomHash()[:types.AssetIDLen]
+		utxos   = []*proto.UTXO{
+			{Hash: util.RandomHash(), OutIndex: 0, Amount: 50, Address: from},
+			{Hash: util.RandomHash(), OutIndex: 1, Amount: 500, Address: from, Asset: asset},
+			{Hash: util.RandomHash(), OutIndex: 2, Amount: 70, Address: from},
+			{Hash: util.RandomHash(), OutIndex: 3, Amount: 1000, Address: from},
+		}
+	)
+
+	tx, err := NewTransfer(privKey, utxos, to, 100, nil)
+	require.Nil(t, err)
+	assert.Nil(t, types.VerifyTransaction(tx))
+	// The asset utxo is skipped, the last one isn't needed.
+	require.Len(t, tx.Inputs, 2)
+	assert.Equal(t, utxos[0].Hash, tx.Inputs[0].PrevTxHash)
+	assert.Equal(t, utxos[2].Hash, tx.Inputs[1].PrevTxHash)
+	assert.Equal(t, uint32(2), tx.Inputs[1].PrevOutIndex)
+	require.Len(t, tx.Outputs, 2)
+	assert.Equal(t, &proto.TxOutput{Amount: 100, Address: to}, tx.Outputs[0])
+	assert.Equal(t, &proto.TxOutput{Amount: 20, Address: from}, tx.Outputs[1])
+
+	tx, err = NewTransfer(privKey, utxos, to, 500, asset)
+	require.Nil(t, err)
+	require.Len(t, tx.Inputs, 1)
+	require.Len(t, tx.Outputs, 1) // No change.
+	assert.Equal(t, asset, tx.Outputs[0].Asset)
+
+	_, err = NewTransfer(privKey, utxos, to, 501, asset)
+	assert.NotNil(t, err)
+	_, err = NewTransfer(crypto.GeneratePrivateKey(), utxos, to, 1, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/Fito305/blocker/client"
+	"github.com/Fito305/blocker/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const defaultAdminAddr = "127.0.0.1:3002"
+
+// adminFlags is nodeFlags for the AdminAPI of the node.
+func adminFlags(fs *flag.FlagSet) func() (proto.AdminAPIClient, func() error, error) {
+	addr := fs.String("admin", envOr("BLOCKER_ADMIN", defaultAdminAddr), "address of the AdminAPI of the node")
+	token := fs.String("admin-token", os.Getenv("BLOCKER_ADMIN_TOKEN"), "token of the AdminAPI of the node")
+	tlsConfig := tlsFlags(fs)
+	return func() (proto.AdminAPIClient, func() error, error) {
+		cfg, err := tlsConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+		opts := []grpc.DialOption{grpc.WithInsecure()}
+		if cfg != nil {
+			opts = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(cfg))}
+		}
+		if *token != "" {
+			opts = append(opts, grpc.WithPerRPCCredentials(client.TokenCredentials(*token)))
+		}
+		conn, err := grpc.Dial(*addr, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return proto.NewAdminAPIClient(conn), conn.Close, nil
+	}
+}
+
+type banJSON struct {
+	Address string    `json:"address"`
+	Until   time.Time `json:"until"`
+	Reason  string    `json:"reason"`
+}
+
+func peersBans(args []string) error {
+	fs := flag.NewFlagSet("peers bans", flag.ContinueOnError)
+	dial := adminFlags(fs)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	admin, closeConn, err := dial()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	resp, err := admin.ListBans(ctx, &proto.ListBansRequest{})
+	if err != nil {
+		return err
+	}
+	bans := make([]banJSON, len(resp.Bans))
+	for i, ban := range resp.Bans {
+		bans[i] = banJSON{
+			Address: ban.Address,
+			Until:   time.Unix(ban.Until, 0).UTC(),
+			Reason:  ban.Reason,
+		}
+	}
+	return printJSON(bans)
+}
+
+func peersBan(args []string) error {
+	fs := flag.NewFlagSet("peers ban", flag.ContinueOnError)
+	var (
+		dial     = adminFlags(fs)
+		duration = fs.Duration("duration", 0, "how long the ban lasts, the default of the node when 0")
+		reason   = fs.String("reason", "", "why, for the ban list")
+	)
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	admin, closeConn, err := dial()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	_, err = admin.Ban(ctx, &proto.BanRequest{
+		Address:  fs.Arg(0),
+		Duration: int64(duration.Seconds()),
+		Reason:   *reason,
+	})
+	return err
+}
+
+func peersUnban(args []string) error {
+	fs := flag.NewFlagSet("peers unban", flag.ContinueOnError)
+	dial := adminFlags(fs)
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	admin, closeConn, err := dial()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	_, err = admin.Unban(ctx, &proto.UnbanRequest{Address: fs.Arg(0)})
+	return err
+}
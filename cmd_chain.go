@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Fito305/blocker/client"
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/node"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	snapshotRecentBlocks = 100         // How many blocks we keep in a snapshot next to the headers and the utxo set.
+	snapshotTimeout      = time.Minute // A snapshot is big, but a node that takes longer than this is stuck.
+)
+
+// chainGetBlock takes a block hash, or a height when the argument is a number.
+func chainGetBlock(args []string) error {
+	fs := flag.NewFlagSet("chain get-block", flag.ContinueOnError)
+	dial := nodeFlags(fs)
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	var block *proto.Block
+	if height, err := strconv.Atoi(fs.Arg(0)); err == nil {
+		block, err = c.GetBlockByHeight(ctx, height)
+		if err != nil {
+			return err
+		}
+	} else {
+		hash, err := hex.DecodeString(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid block hash or height %q", fs.Arg(0))
+		}
+		if block, err = c.GetBlock(ctx, hash); err != nil {
+			return err
+		}
+	}
+	return printJSON(types.NewBlockJSON(block))
+}
+
+func chainGetTx(args []string) error {
+	fs := flag.NewFlagSet("chain get-tx", flag.ContinueOnError)
+	dial := nodeFlags(fs)
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	hash, err := hex.DecodeString(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid tx hash %q", fs.Arg(0))
+	}
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	status, err := c.GetTransaction(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if status.Status == proto.TxStatus_TX_UNKNOWN {
+		return fmt.Errorf("tx [%s] not found", fs.Arg(0))
+	}
+	return printJSON(node.NewTxStatusJSON(status))
+}
+
+// chainSnapshot writes a snapshot of the chain of a running node to a file. Start a new
+// node from it with snapshotFile and trustedBlockHash in its config. Snapshots are served
+// by the PeerService, so --peer is the listen address of the node, not its PublicAPI.
+// With peerTLS we show a certificate like a node does, in a permissioned network one signed
+// by its CA for the key in --node-key.
+func chainSnapshot(args []string) error {
+	fs := flag.NewFlagSet("chain snapshot", flag.ContinueOnError)
+	var (
+		peerAddr  = fs.String("peer", "", "listen address of the node")
+		peerToken = fs.String("peer-token", "", "peer token of the network")
+		peerTLS   = fs.Bool("peer-tls", false, "the network has peerTLS")
+		peerCA    = fs.String("peer-ca", "", "CA file of a permissioned network, implies --peer-tls")
+		peerCert  = fs.String("peer-cert", "", "certificate file of --node-key signed by the CA, implies --peer-tls")
+		nodeKey   = fs.String("node-key", "", "key file the --peer-cert is for, a new key when empty")
+	)
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	if *peerAddr == "" {
+		return fmt.Errorf("--peer is required")
+	}
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if *peerTLS || *peerCA != "" || *peerCert != "" {
+		key := crypto.GeneratePrivateKey()
+		if *nodeKey != "" {
+			var err error
+			if key, err = crypto.ReadPrivateKeyFile(*nodeKey); err != nil {
+				return err
+			}
+		}
+		cfg, err := node.PeerTLSConfig(key, node.TLSConfig{CertFile: *peerCert, CAFile: *peerCA})
+		if err != nil {
+			return err
+		}
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(cfg))}
+	}
+	if *peerToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(client.TokenCredentials(*peerToken)))
+	}
+	conn, err := grpc.Dial(*peerAddr, opts...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	ctx, cancel = context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+	snap, err := proto.NewPeerServiceClient(conn).GetSnapshot(ctx, &proto.SnapshotRequest{
+		RecentBlocks: snapshotRecentBlocks,
+	})
+	if err != nil {
+		return err
+	}
+	// We don't know the trusted hash yet, the node checks the rest when it starts from the file.
+	tip, err := node.SnapshotTip(snap)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot from %s: %w", *peerAddr, err)
+	}
+	file := fs.Arg(0)
+	if err := node.WriteSnapshotFile(file, snap); err != nil {
+		return err
+	}
+	log.Printf("wrote snapshot at height %d block %s to %s", snap.Height, hex.EncodeToString(types.HashHeader(tip)), file)
+	return nil
+}
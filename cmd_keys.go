@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Fito305/blocker/crypto"
+)
+
+type keyJSON struct {
+	PublicKey string `json:"publicKey"`
+	Address   string `json:"address"`
+	Seed      string `json:"seed,omitempty"` // Only printed when we don't write the key to a file.
+	File      string `json:"file,omitempty"`
+}
+
+func keysGenerate(args []string) error {
+	fs := flag.NewFlagSet("keys generate", flag.ContinueOnError)
+	out := fs.String("out", "", "write the key to this file instead of printing it")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	privKey := crypto.GeneratePrivateKey()
+	resp := keyJSON{
+		PublicKey: hex.EncodeToString(privKey.Public().Bytes()),
+		Address:   privKey.Public().Address().String(),
+	}
+	if *out == "" {
+		resp.Seed = hex.EncodeToString(privKey.Seed())
+		return printJSON(resp)
+	}
+	if err := crypto.WritePrivateKeyFile(*out, privKey); err != nil {
+		return err
+	}
+	resp.File = *out
+	return printJSON(resp)
+}
+
+func keysShow(args []string) error {
+	fs := flag.NewFlagSet("keys show", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "path of the key file")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *keyFile == "" {
+		return fmt.Errorf("--key is required")
+	}
+	privKey, err := crypto.ReadPrivateKeyFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	return printJSON(keyJSON{
+		PublicKey: hex.EncodeToString(privKey.Public().Bytes()),
+		Address:   privKey.Public().Address().String(),
+		File:      *keyFile,
+	})
+}
+
+// keysCSR prints a certificate request for a node key. The CA of a permissioned network signs it,
+// `openssl x509 -req -in node.csr -CA ca.pem -CAkey ca.key -days 365 -out node.pem` for example,
+// and the node gets the certificate as certFile of its peerTLS.
+func keysCSR(args []string) error {
+	fs := flag.NewFlagSet("keys csr", flag.ContinueOnError)
+	var (
+		keyFile = fs.String("key", "", "path of the node key file")
+		name    = fs.String("name", "", "common name of the certificate, the node ID when empty")
+	)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *keyFile == "" {
+		return fmt.Errorf("--key is required")
+	}
+	privKey, err := crypto.ReadPrivateKeyFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		*name = hex.EncodeToString(privKey.Public().Bytes())
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: *name},
+	}, ed25519.NewKeyFromSeed(privKey.Seed()))
+	if err != nil {
+		return err
+	}
+	return pem.Encode(os.Stdout, &pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Fito305/blocker/node"
+)
+
+func nodeStart(args []string) error {
+	fs := flag.NewFlagSet("node start", flag.ContinueOnError)
+	configFile := fs.String("config", "", "path of the config file of the node")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	cfg, err := node.LoadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	serverCfg, err := cfg.ServerConfig()
+	if err != nil {
+		return err
+	}
+	n := node.NewNode(serverCfg)
+
+	// On ctrl-c or SIGTERM we stop the node, so it says goodbye to its peers and saves what it has to.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- n.Start(cfg.ListenAddr, cfg.BootstrapNodes)
+	}()
+	select {
+	case err := <-errc:
+		n.Stop()
+		return err
+	case <-ctx.Done():
+		n.Stop()
+		return <-errc
+	}
+}
+
+type peerJSON struct {
+	ListenAddr   string `json:"listenAddr"`
+	Version      string `json:"version"`
+	Height       int    `json:"height"`
+	Outbound     bool   `json:"outbound"`
+	Score        int    `json:"score"`
+	NodeID       string `json:"nodeId"`
+	Pruned       bool   `json:"pruned"`
+	LowestHeight int    `json:"lowestHeight"`
+}
+
+func peersList(args []string) error {
+	fs := flag.NewFlagSet("peers list", flag.ContinueOnError)
+	dial := nodeFlags(fs)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	peers, err := c.GetPeers(ctx)
+	if err != nil {
+		return err
+	}
+	resp := make([]peerJSON, len(peers))
+	for i, peer := range peers {
+		resp[i] = peerJSON{
+			ListenAddr:   peer.ListenAddr,
+			Version:      peer.Version,
+			Height:       int(peer.Height),
+			Outbound:     peer.Outbound,
+			Score:        int(peer.Score),
+			NodeID:       hex.EncodeToString(peer.NodeId),
+			Pruned:       peer.Pruned,
+			LowestHeight: int(peer.LowestHeight),
+		}
+	}
+	return printJSON(resp)
+}
+
+// commandContext is cancelled on ctrl-c, so a command waiting on a node can be stopped.
+func commandContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
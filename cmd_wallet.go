@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/node"
+	"github.com/Fito305/blocker/types"
+)
+
+func walletBalance(args []string) error {
+	fs := flag.NewFlagSet("wallet balance", flag.ContinueOnError)
+	dial := nodeFlags(fs)
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	address, err := decodeAddress(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	balance, err := c.GetBalance(ctx, address)
+	if err != nil {
+		return err
+	}
+	// Same as GET /address/{address}/balance of the JSON API.
+	resp := map[string]uint64{}
+	for asset, amount := range balance {
+		if asset == node.NativeAsset {
+			asset = "native"
+		}
+		resp[asset] = amount
+	}
+	return printJSON(resp)
+}
+
+func walletSend(args []string) error {
+	fs := flag.NewFlagSet("wallet send", flag.ContinueOnError)
+	var (
+		dial    = nodeFlags(fs)
+		keyFile = fs.String("key", "", "path of the key file to pay with")
+		to      = fs.String("to", "", "address to send to")
+		amount  = fs.Uint64("amount", 0, "amount to send")
+		asset   = fs.String("asset", "", "asset ID to send, the native coin when empty")
+		wait    = fs.Int("wait", 0, "wait until the tx has this many confirmations")
+	)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *keyFile == "" {
+		return fmt.Errorf("--key is required")
+	}
+	privKey, err := crypto.ReadPrivateKeyFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	toAddress, err := decodeAddress(*to)
+	if err != nil {
+		return err
+	}
+	var assetID []byte
+	if *asset != "" {
+		if assetID, err = hex.DecodeString(*asset); err != nil || len(assetID) != types.AssetIDLen {
+			return fmt.Errorf("invalid asset id %q", *asset)
+		}
+	}
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	hash, err := c.Transfer(ctx, privKey, toAddress, *amount, assetID)
+	if err != nil {
+		return err
+	}
+	if *wait <= 0 {
+		return printJSON(map[string]string{"hash": hex.EncodeToString(hash)})
+	}
+	status, err := c.WaitForConfirmation(ctx, hash, *wait)
+	if err != nil {
+		return err
+	}
+	return printJSON(node.NewTxStatusJSON(status))
+}
+
+func decodeAddress(s string) ([]byte, error) {
+	address, err := hex.DecodeString(s)
+	if err != nil || len(address) != crypto.AddressLen {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+	return address, nil
+}
@@ -0,0 +1,43 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A key file holds the hex encoded seed of a private key, nothing else.
+// Whoever can read it can sign for the key, so only we can read or write it.
+
+func (p *PrivateKey) Seed() []byte {
+	return p.key.Seed()
+}
+
+// WritePrivateKeyFile never overwrites an existing file, losing a key means losing its funds.
+func WritePrivateKeyFile(path string, p *PrivateKey) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(hex.EncodeToString(p.Seed()) + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func ReadPrivateKeyFile(path string) (*PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+	if len(seed) != SeedLen {
+		return nil, fmt.Errorf("invalid key file %s: seed must be %d bytes, got (%d)", path, SeedLen, len(seed))
+	}
+	return NewPrivateKeyFromSeed(seed), nil
+}
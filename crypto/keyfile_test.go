@@ -0,0 +1,32 @@
+package crypto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrivateKeyFile(t *testing.T) {
+	var (
+		path    = filepath.Join(t.TempDir(), "validator.key")
+		privKey = GeneratePrivateKey()
+	)
+	require.Nil(t, WritePrivateKeyFile(path, privKey))
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// We never overwrite a key.
+	assert.NotNil(t, WritePrivateKeyFile(path, GeneratePrivateKey()))
+
+	loaded, err := ReadPrivateKeyFile(path)
+	require.Nil(t, err)
+	assert.Equal(t, privKey.Bytes(), loaded.Bytes())
+
+	require.Nil(t, os.WriteFile(path, []byte("abcd"), 0600))
+	_, err = ReadPrivateKeyFile(path)
+	assert.NotNil(t, err)
+}
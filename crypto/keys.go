@@ -85,13 +85,14 @@ type PublicKey struct {
 	key ed25519.PublicKey
 }
 
-func PublicKeyFromBytes(b []byte) *PublicKey {
+// PublicKeyFromBytes fails on anything that isn't PubKeyLen bytes, keys come from the network.
+func PublicKeyFromBytes(b []byte) (*PublicKey, error) {
 	if len(b) != PubKeyLen {
-		panic("invalid public key length")
+		return nil, fmt.Errorf("invalid public key length (%d)", len(b))
 	}
 	return &PublicKey{
 		key: ed25519.PublicKey(b),
-	}
+	}, nil
 }
 
 func (p *PublicKey) Address() Address {
@@ -108,13 +109,13 @@ type Signature struct {
 	value []byte
 }
 
-func SignatureFromBytes(b []byte) *Signature {
+func SignatureFromBytes(b []byte) (*Signature, error) {
 	if len(b) != SignatureLen {
-		panic("length of the bytes not equal to 64")
+		return nil, fmt.Errorf("invalid signature length (%d)", len(b))
 	}
 	return &Signature{
 		value: b,
-	}
+	}, nil
 }
 
 func (s *Signature) Bytes() []byte {
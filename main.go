@@ -1,78 +1,160 @@
 package main
 
 import (
-	"context"
-	"log"
-	"time"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
 
-	"github.com/Fito305/blocker/crypto"
-	"github.com/Fito305/blocker/node"
-	"github.com/Fito305/blocker/proto"
-	"github.com/Fito305/blocker/util"
-	"google.golang.org/grpc"
+	"github.com/Fito305/blocker/client"
 )
 
-func main() {
-	// seed := util.RandomHash() // Delete this after you grab the hash from the terminal.
-	// fmt.Println(hex.EncodeToString(seed))
-	// return // use to return early and copy the seed hash. Use that hash for the Genesis block.
-	// SO what is going to happen is, we are going to spin up :3000, he doesnt know anything. Then :4000 is going to have this :3000 that is bootstrapNode(). So what is going to happen is :4000 is going to dail (handshake/conncect) with :3000. What is going to happen is that htye are going to exchange versions, so they both are going to have each other in their peer map. So :3000 is going to connect to :4000 and :4000 is going to be connected with :3000. Look at :5000 comment.
-	makeNode(":3000", []string{}, true) // The bool is validator
-	time.Sleep(time.Second) // We need to sleep here. It's very important we need to give it time. We are making a node and then we are making another node directly after it. So it's could be that :3000 is not ready yet.
-	makeNode(":4000", []string{":3000"}, false)
-	time.Sleep(4 * time.Second)
-	makeNode(":5000", []string{":4000"}, false) // But the we spin up node :5000. And :5000 is only aware and is going to connect to :4000. So what is going to happen is :4000 is going to connect with :5000. And :5000 is going to connect with :4000. They are going to have each other in their peer map. But :5000 is not going to be connected with :3000. But thanks to the peer list, that is going to be sent by :4000 is connected all the way to :3000. :5000 has the chance the ability to also be aware of :3000. And that is how the whole network is going to be boostraped.
+const usage = `blocker is a node and a wallet for the blocker network.
+
+Usage:
+  blocker node start --config <file>
+  blocker keys generate [--out <file>]
+  blocker keys show --key <file>
+  blocker keys csr --key <file> [--name <name>]
+  blocker wallet balance [--node <addr>] <address>
+  blocker wallet send [--node <addr>] --key <file> --to <address> --amount <n> [--asset <id>] [--wait <confirmations>]
+  blocker chain get-block [--node <addr>] <hash or height>
+  blocker chain get-tx [--node <addr>] <hash>
+  blocker chain snapshot --peer <addr> [--peer-token <token>] [--peer-tls] [--peer-ca <file>] [--peer-cert <file> --node-key <file>] <file>
+  blocker peers list [--node <addr>]
+  blocker peers bans [--admin <addr>]
+  blocker peers ban [--admin <addr>] [--duration <1h>] [--reason <text>] <address>
+  blocker peers unban [--admin <addr>] <address>
+
+Commands that talk to a node use its PublicAPI, --node defaults to $BLOCKER_NODE or 127.0.0.1:3001.
+Set --token (or $BLOCKER_TOKEN) when the node wants a PublicToken, --tls (or --tls-ca, --tls-cert
+and --tls-key) when it has publicTLS. The AdminAPI takes the same tls flags.
+Peer bans use the AdminAPI, --admin defaults to $BLOCKER_ADMIN or 127.0.0.1:3002 and
+--admin-token to $BLOCKER_ADMIN_TOKEN.
+Flags go before the arguments.
+`
 
-	for { // going to keep sending transactions every 2 seconds. if you have a block time of 5, you are going to have 2 transactions each block.
-		time.Sleep(time.Second)
-		makeTransaction()
+const defaultNodeAddr = "127.0.0.1:3001"
+
+type command func(args []string) error
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
 	}
+}
 
+func run(args []string) error {
+	return dispatch(args, map[string]command{
+		"node": func(args []string) error {
+			return dispatch(args, map[string]command{"start": nodeStart})
+		},
+		"keys": func(args []string) error {
+			return dispatch(args, map[string]command{"generate": keysGenerate, "show": keysShow, "csr": keysCSR})
+		},
+		"wallet": func(args []string) error {
+			return dispatch(args, map[string]command{"balance": walletBalance, "send": walletSend})
+		},
+		"chain": func(args []string) error {
+			return dispatch(args, map[string]command{"get-block": chainGetBlock, "get-tx": chainGetTx, "snapshot": chainSnapshot})
+		},
+		"peers": func(args []string) error {
+			return dispatch(args, map[string]command{"list": peersList, "bans": peersBans, "ban": peersBan, "unban": peersUnban})
+		},
+	})
 }
 
-func makeNode(listenAddr string, bootstrapNodes []string, isValidator bool) *node.Node {
-	cfg := node.ServerConfig{
-		Version: "Blocker-1",
-		ListenAddr: listenAddr,
+func dispatch(args []string, commands map[string]command) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Print(usage)
+		return nil
 	}
-	if isValidator {
-		cfg.PrivateKey = crypto.GeneratePrivateKey()
+	cmd, ok := commands[args[0]]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command %q, expected one of: %s", args[0], strings.Join(names, ", "))
 	}
-	n := node.NewNode(cfg)
-	go n.Start(listenAddr, bootstrapNodes)
-	return n
+	return cmd(args[1:])
 }
 
-func makeTransaction() {
-	privKey := crypto.GeneratePrivateKey()
-	client, err := grpc.Dial(":3000", grpc.WithInsecure())
-	if err != nil {
-		log.Fatal(err)
+// nodeFlags adds the flags every command that talks to a node has. Call the returned
+// func after parsing to connect.
+func nodeFlags(fs *flag.FlagSet) func() (*client.Client, error) {
+	addr := fs.String("node", envOr("BLOCKER_NODE", defaultNodeAddr), "address of the PublicAPI of the node")
+	token := fs.String("token", os.Getenv("BLOCKER_TOKEN"), "token of the PublicAPI of the node")
+	tlsConfig := tlsFlags(fs)
+	return func() (*client.Client, error) {
+		cfg, err := tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		return client.Dial(*addr, client.WithToken(*token), client.WithPoolSize(1), client.WithTLS(cfg))
 	}
+}
 
-	c := proto.NewNodeClient(client)
+// tlsFlags adds the flags to talk to an api of a node over TLS. The returned func gives nil when none is set.
+func tlsFlags(fs *flag.FlagSet) func() (*tls.Config, error) {
+	var (
+		on       = fs.Bool("tls", false, "connect over tls, checking the certificate of the node with the system CAs")
+		caFile   = fs.String("tls-ca", "", "CA file to check the certificate of the node with, implies --tls")
+		certFile = fs.String("tls-cert", "", "client certificate file, when the node wants one. Implies --tls")
+		keyFile  = fs.String("tls-key", "", "key file of the client certificate")
+	)
+	return func() (*tls.Config, error) {
+		if !*on && *caFile == "" && *certFile == "" {
+			return nil, nil
+		}
+		cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if *caFile != "" {
+			b, err := os.ReadFile(*caFile)
+			if err != nil {
+				return nil, err
+			}
+			cfg.RootCAs = x509.NewCertPool()
+			if !cfg.RootCAs.AppendCertsFromPEM(b) {
+				return nil, fmt.Errorf("no certificate in %s", *caFile)
+			}
+		}
+		if *certFile != "" {
+			cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Certificates = []tls.Certificate{cert}
+		}
+		return cfg, nil
+	}
+}
 
-	// We are going to make a simple transaction that is not valid but we are going to put some values into it jsut for the sake of it.
-	// Because we are not validating right now.
-	tx := &proto.Transaction{
-		Version: 1,
-		Inputs: []*proto.TxInput{
-			{
-				PrevTxHash:   util.RandomHash(),
-				PrevOutIndex: 0,
-				PublicKey:    privKey.Public().Bytes(),
-			},
-		},
-		Outputs: []*proto.TxOutput{
-			{
-				Amount:  99,
-				Address: privKey.Public().Address().Bytes(),
-			},
-		},
+func envOr(key string, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
+	return fallback
+}
 
-	_, err = c.HandleTransaction(context.TODO(), tx)
-	if err != nil {
-		log.Fatal(err)
+// parseArgs parses the flags and checks we got exactly n arguments after them.
+func parseArgs(fs *flag.FlagSet, args []string, n int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != n {
+		return fmt.Errorf("%s takes %d argument(s), got (%d)", fs.Name(), n, fs.NArg())
 	}
+	return nil
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
 }
@@ -0,0 +1,99 @@
+package node
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Peers are keyed by their canonical address: host:port with a lowercase host, ips in their
+// shortest form and ipv6 in brackets. "localhost:3000", "127.0.0.1:3000" and ":3000" are still
+// different strings for the same node, that's why peers also tell us their nodeId.
+
+// normalizeAddr checks addr is host:port and returns it in canonical form. The host can be empty.
+func normalizeAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(strings.TrimSpace(addr))
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p >= 1<<16 {
+		return "", fmt.Errorf("invalid port in address %q", addr)
+	}
+	host = strings.ToLower(host)
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(p)), nil
+}
+
+// canonicalAddr is normalizeAddr for an address we can dial. Without a host, or with 0.0.0.0,
+// the node is at observedIP, the ip we see the connection coming from, or on this machine.
+func canonicalAddr(addr string, observedIP string) (string, error) {
+	addr, err := normalizeAddr(addr)
+	if err != nil {
+		return "", err
+	}
+	host, port, _ := net.SplitHostPort(addr)
+	if hasHost(host) {
+		return addr, nil
+	}
+	if observedIP == "" {
+		observedIP = "127.0.0.1"
+	}
+	return normalizeAddr(net.JoinHostPort(observedIP, port))
+}
+
+// hasHost reports whether host says where a node is, "" and 0.0.0.0 only say which port.
+func hasHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsUnspecified()
+}
+
+// advertisedAddr is the address we tell our peers to dial us at.
+func (n *Node) advertisedAddr() string {
+	if n.AdvertiseAddr != "" {
+		return n.AdvertiseAddr
+	}
+	addr, err := normalizeAddr(n.ListenAddr)
+	if err != nil {
+		return n.ListenAddr
+	}
+	host, port, _ := net.SplitHostPort(addr)
+	if hasHost(host) {
+		return addr
+	}
+	return net.JoinHostPort("", port) // The peer fills in the ip it sees us at.
+}
+
+// addSelfAddr remembers addr is us, so we never dial it.
+func (n *Node) addSelfAddr(addr string) {
+	n.peerLock.Lock()
+	defer n.peerLock.Unlock()
+	n.selfAddrs[addr] = true
+}
+
+func (n *Node) isSelfAddr(addr string) bool {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+	return n.selfAddrs[addr]
+}
+
+// learnObservedIP remembers the address a peer sees us at. Behind NAT that's the only way we find out
+// our own address, and without it we would dial ourselves when another peer tells us about it.
+func (n *Node) learnObservedIP(ip string) {
+	if net.ParseIP(ip) == nil {
+		return
+	}
+	_, port, err := net.SplitHostPort(n.ListenAddr)
+	if err != nil {
+		return
+	}
+	if addr, err := normalizeAddr(net.JoinHostPort(ip, port)); err == nil {
+		n.addSelfAddr(addr)
+	}
+}
@@ -0,0 +1,93 @@
+package node
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCanonicalAddr(t *testing.T) {
+	for addr, want := range map[string]string{
+		"127.0.0.1:3000":      "127.0.0.1:3000",
+		" Node-1.Example:80 ": "node-1.example:80",
+		"[::FFFF:1.2.3.4]:80": "1.2.3.4:80",
+		"[2001:DB8::1]:3000":  "[2001:db8::1]:3000",
+		":3000":               "10.0.0.1:3000",
+		"0.0.0.0:3000":        "10.0.0.1:3000",
+	} {
+		got, err := canonicalAddr(addr, "10.0.0.1")
+		require.Nil(t, err, addr)
+		assert.Equal(t, want, got, addr)
+	}
+	got, err := canonicalAddr(":3000", "")
+	require.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:3000", got)
+
+	for _, addr := range []string{"", "127.0.0.1", "127.0.0.1:0", "127.0.0.1:70000", "127.0.0.1:http"} {
+		_, err := canonicalAddr(addr, "10.0.0.1")
+		assert.NotNil(t, err, addr)
+	}
+}
+
+func TestHandshakeUsesObservedIPAndNodeID(t *testing.T) {
+	var (
+		n   = NewNode(ServerConfig{ListenAddr: ":3000"})
+		ctx = guardContext("10.0.0.5", "")
+	)
+	assert.Equal(t, ":3000", n.getVersion().ListenAddr)
+
+	// No host, the peer is where the call came from.
+	key := crypto.GeneratePrivateKey()
+	resp, err := handshake(t, ctx, n, key, ":4000")
+	require.Nil(t, err)
+	assert.Equal(t, "10.0.0.5", resp.ObservedIp)
+	assert.Equal(t, []string{"10.0.0.5:4000"}, n.getPeerList())
+
+	// The same node under another name.
+	_, err = handshake(t, ctx, n, key, "node-5.example:4000")
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = handshake(t, ctx, n, crypto.GeneratePrivateKey(), "10.0.0.5")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// We dialed ourselves, we answer so our other side sees its own nodeId.
+	resp, err = handshake(t, context.Background(), n, n.NodeKey, ":3000")
+	require.Nil(t, err)
+	assert.Equal(t, n.nodeID, resp.NodeId)
+	assert.Equal(t, []string{"10.0.0.5:4000"}, n.getPeerList())
+
+	n = NewNode(ServerConfig{ListenAddr: ":3000", AdvertiseAddr: "203.0.113.7:3000"})
+	assert.Equal(t, "203.0.113.7:3000", n.getVersion().ListenAddr)
+}
+
+func TestNodeDetectsItselfAndDuplicateAddresses(t *testing.T) {
+	var (
+		addrA = freeAddr(t)
+		addrB = freeAddr(t)
+		a     = NewNode(ServerConfig{})
+		b     = NewNode(ServerConfig{})
+	)
+	defer a.Stop()
+	defer b.Stop()
+	_, portA, _ := net.SplitHostPort(addrA)
+	_, portB, _ := net.SplitHostPort(addrB)
+
+	go a.Start(addrA, nil)
+	waitForListener(t, addrA)
+	// Three names for a and one for ourselves.
+	go b.Start(addrB, []string{"localhost:" + portB, "localhost:" + portA, addrA, ":" + portA})
+
+	require.Eventually(t, func() bool {
+		return b.isSelfAddr("localhost:"+portB) && len(b.getPeerList()) == 1 && len(a.getPeerList()) == 1
+	}, 3*time.Second, 10*time.Millisecond)
+	assert.Never(t, func() bool {
+		return len(b.getPeerList()) > 1 || len(a.getPeerList()) > 1
+	}, 300*time.Millisecond, 10*time.Millisecond)
+}
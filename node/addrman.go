@@ -0,0 +1,422 @@
+package node
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The addrManager remembers the addresses of the network we heard about, so we can pick our
+// outbound peers from them, also after a restart. It works like the addrman of Bitcoin: addresses
+// we never connected to go into the new table, the ones we connected to move to the tried table.
+// Both tables are split in buckets and where an address ends up depends on a secret key, its
+// network group and, for new addresses, the group of the peer that told us. A peer sending us
+// thousands of addresses, or a lot of addresses in one network, only fills a few buckets,
+// so it can't push out everybody else and surround us with its own nodes.
+
+const (
+	newBucketCount           = 256
+	newBucketsPerSourceGroup = 32
+	triedBucketCount         = 64
+	triedBucketsPerGroup     = 8
+	addrBucketSize           = 64
+	maxAddrsPerMessage       = 1000 // The most we send in, and take from, one AddrList.
+	maxAddrFailures          = 10   // Failed attempts in a row before an address is terrible.
+	maxAddrPicks             = 64
+	addrHorizon              = 30 * 24 * time.Hour // Addresses nobody mentioned for this long are terrible.
+	addrSaveInterval         = 5 * time.Minute
+	addrFileName             = "peers.json"
+)
+
+type knownAddr struct {
+	Addr        string    `json:"addr"`
+	Source      string    `json:"source"` // Who told us about it.
+	Tried       bool      `json:"tried"`
+	LastSeen    time.Time `json:"lastSeen"`
+	LastAttempt time.Time `json:"lastAttempt"`
+	LastSuccess time.Time `json:"lastSuccess"`
+	Failures    int       `json:"failures"` // Attempts since the last success.
+
+	bucket int
+}
+
+// terrible addresses are the first to go when a bucket is full, and we don't hand them out.
+func (ka *knownAddr) terrible(now time.Time) bool {
+	if now.Sub(ka.LastSeen) > addrHorizon {
+		return true
+	}
+	return ka.Failures >= maxAddrFailures
+}
+
+// retryAt is when we can dial the address again, we wait twice as long after each failure.
+func (ka *knownAddr) retryAt() time.Time {
+	if ka.Failures == 0 {
+		return ka.LastAttempt
+	}
+	backoff := minReconnectBackoff << min(ka.Failures-1, 16)
+	return ka.LastAttempt.Add(min(backoff, maxReconnectBackoff))
+}
+
+type addrFile struct {
+	Key   string       `json:"key"` // hex
+	Addrs []*knownAddr `json:"addrs"`
+}
+
+type addrManager struct {
+	path string // empty means we forget everything on restart.
+
+	lock  sync.Mutex
+	key   [32]byte
+	addrs map[string]*knownAddr
+	new   [newBucketCount]map[string]*knownAddr
+	tried [triedBucketCount]map[string]*knownAddr
+	rand  *mrand.Rand
+}
+
+func newAddrManager(path string) *addrManager {
+	am := &addrManager{
+		path:  path,
+		addrs: make(map[string]*knownAddr),
+		rand:  mrand.New(mrand.NewSource(time.Now().UnixNano())),
+	}
+	if _, err := rand.Read(am.key[:]); err != nil {
+		panic(err)
+	}
+	am.reset()
+	return am
+}
+
+// reset needs the lock.
+func (am *addrManager) reset() {
+	am.addrs = make(map[string]*knownAddr)
+	for i := range am.new {
+		am.new[i] = make(map[string]*knownAddr)
+	}
+	for i := range am.tried {
+		am.tried[i] = make(map[string]*knownAddr)
+	}
+}
+
+// dialableAddr returns addr in canonical form. False means it doesn't say where to dial, so it's useless to us.
+func dialableAddr(addr string) (string, bool) {
+	addr, err := normalizeAddr(addr)
+	if err != nil {
+		return "", false
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	return addr, hasHost(host)
+}
+
+// addrGroup is the network an address is in: the /16 of an ipv4 address, the /32 of an ipv6
+// address. Somebody who owns a lot of addresses usually owns them in the same few groups.
+func addrGroup(addr string) string {
+	host := banAddress(addr)
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "host:" + host
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("ipv4:%d.%d", ip4[0], ip4[1])
+	}
+	return "ipv6:" + hex.EncodeToString(ip[:4])
+}
+
+func (am *addrManager) hash(parts ...string) uint64 {
+	h := sha256.New()
+	h.Write(am.key[:])
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+func (am *addrManager) newBucket(addr string, source string) int {
+	srcGroup := addrGroup(source)
+	i := am.hash(addrGroup(addr), srcGroup) % newBucketsPerSourceGroup
+	return int(am.hash(srcGroup, strconv.FormatUint(i, 10)) % newBucketCount)
+}
+
+func (am *addrManager) triedBucket(addr string) int {
+	i := am.hash(addr) % triedBucketsPerGroup
+	return int(am.hash(addrGroup(addr), strconv.FormatUint(i, 10)) % triedBucketCount)
+}
+
+// load reads the addresses we saved before we restarted. No file is fine, we just start out empty.
+func (am *addrManager) load() error {
+	if am.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(am.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var file addrFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid address file %s: %w", am.path, err)
+	}
+	key, err := hex.DecodeString(file.Key)
+	if err != nil || len(key) != len(am.key) {
+		return fmt.Errorf("invalid address file %s: invalid key", am.path)
+	}
+
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	copy(am.key[:], key)
+	am.reset()
+	for _, ka := range file.Addrs {
+		addr, ok := dialableAddr(ka.Addr)
+		if !ok || am.addrs[addr] != nil {
+			continue
+		}
+		ka.Addr = addr
+		if ka.Tried {
+			am.addTried(ka)
+		} else {
+			am.addNew(ka)
+		}
+	}
+	return nil
+}
+
+func (am *addrManager) save() error {
+	if am.path == "" {
+		return nil
+	}
+	am.lock.Lock()
+	file := addrFile{
+		Key:   hex.EncodeToString(am.key[:]),
+		Addrs: make([]*knownAddr, 0, len(am.addrs)),
+	}
+	for _, ka := range am.addrs {
+		cp := *ka
+		file.Addrs = append(file.Addrs, &cp)
+	}
+	am.lock.Unlock()
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(am.path, data)
+}
+
+func (am *addrManager) len() int {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+	return len(am.addrs)
+}
+
+// add adds the addresses source told us about and returns how many we didn't know yet.
+// We take at most maxAddrsPerMessage from one call.
+func (am *addrManager) add(addrs []string, source string) int {
+	if len(addrs) > maxAddrsPerMessage {
+		addrs = addrs[:maxAddrsPerMessage]
+	}
+	now := time.Now()
+
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	added := 0
+	for _, addr := range addrs {
+		addr, ok := dialableAddr(addr)
+		if !ok {
+			continue
+		}
+		if ka, ok := am.addrs[addr]; ok {
+			ka.LastSeen = now
+			continue
+		}
+		am.addNew(&knownAddr{
+			Addr:     addr,
+			Source:   source,
+			LastSeen: now,
+		})
+		added++
+	}
+	return added
+}
+
+// addNew needs the lock. When the bucket is full a terrible or else the oldest address makes room.
+func (am *addrManager) addNew(ka *knownAddr) {
+	ka.Tried = false
+	ka.bucket = am.newBucket(ka.Addr, ka.Source)
+	bucket := am.new[ka.bucket]
+	if len(bucket) >= addrBucketSize {
+		am.remove(am.evictionCandidate(bucket, func(ka *knownAddr) time.Time { return ka.LastSeen }))
+	}
+	bucket[ka.Addr] = ka
+	am.addrs[ka.Addr] = ka
+}
+
+// addTried needs the lock. When the bucket is full the address we connected to the longest ago goes back to the new table.
+func (am *addrManager) addTried(ka *knownAddr) {
+	ka.Tried = true
+	ka.bucket = am.triedBucket(ka.Addr)
+	bucket := am.tried[ka.bucket]
+	if len(bucket) >= addrBucketSize {
+		old := am.evictionCandidate(bucket, func(ka *knownAddr) time.Time { return ka.LastSuccess })
+		am.remove(old)
+		am.addNew(old)
+	}
+	bucket[ka.Addr] = ka
+	am.addrs[ka.Addr] = ka
+}
+
+func (am *addrManager) evictionCandidate(bucket map[string]*knownAddr, age func(*knownAddr) time.Time) *knownAddr {
+	var (
+		now    = time.Now()
+		oldest *knownAddr
+	)
+	for _, ka := range bucket {
+		if ka.terrible(now) {
+			return ka
+		}
+		if oldest == nil || age(ka).Before(age(oldest)) {
+			oldest = ka
+		}
+	}
+	return oldest
+}
+
+// remove needs the lock.
+func (am *addrManager) remove(ka *knownAddr) {
+	if ka.Tried {
+		delete(am.tried[ka.bucket], ka.Addr)
+	} else {
+		delete(am.new[ka.bucket], ka.Addr)
+	}
+	delete(am.addrs, ka.Addr)
+}
+
+// attempt marks that we are dialing addr, so we don't pick it again right away.
+func (am *addrManager) attempt(addr string) {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	if ka, ok := am.addrs[addr]; ok {
+		ka.LastAttempt = time.Now()
+		ka.Failures++
+	}
+}
+
+// good marks that we connected to addr, it moves to the tried table.
+func (am *addrManager) good(addr string) {
+	addr, ok := dialableAddr(addr)
+	if !ok {
+		return
+	}
+	now := time.Now()
+
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	ka, ok := am.addrs[addr]
+	if !ok {
+		ka = &knownAddr{Addr: addr, Source: addr}
+	}
+	ka.LastSeen = now
+	ka.LastSuccess = now
+	ka.Failures = 0
+	if ka.Tried {
+		return
+	}
+	if ok {
+		am.remove(ka)
+	}
+	am.addTried(ka)
+}
+
+// pick returns an address to dial, half of the time one we connected to before. Like the buckets
+// are filled, a random bucket is picked first, so a source that filled its buckets doesn't get picked more.
+// It skips the addresses skip returns true for and the ones we tried too recently. False means there is none.
+func (am *addrManager) pick(skip func(addr string) bool) (string, bool) {
+	now := time.Now()
+
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	var (
+		tried = dialable(am.tried[:], now)
+		fresh = dialable(am.new[:], now)
+	)
+	for i := 0; i < maxAddrPicks && len(tried)+len(fresh) > 0; i++ {
+		buckets := &fresh
+		if len(fresh) == 0 || (len(tried) > 0 && am.rand.Intn(2) == 0) {
+			buckets = &tried
+		}
+		b := am.rand.Intn(len(*buckets))
+		bucket := (*buckets)[b]
+		j := am.rand.Intn(len(bucket))
+		ka := bucket[j]
+
+		// Don't pick it again.
+		bucket[j] = bucket[len(bucket)-1]
+		bucket = bucket[:len(bucket)-1]
+		if len(bucket) == 0 {
+			(*buckets)[b] = (*buckets)[len(*buckets)-1]
+			*buckets = (*buckets)[:len(*buckets)-1]
+		} else {
+			(*buckets)[b] = bucket
+		}
+
+		if !skip(ka.Addr) {
+			return ka.Addr, true
+		}
+	}
+	return "", false
+}
+
+// dialable needs the lock. It returns the addresses of each bucket we can dial now, leaving out empty buckets.
+func dialable(buckets []map[string]*knownAddr, now time.Time) [][]*knownAddr {
+	var result [][]*knownAddr
+	for _, bucket := range buckets {
+		var addrs []*knownAddr
+		for _, ka := range bucket {
+			if !ka.terrible(now) && !now.Before(ka.retryAt()) {
+				addrs = append(addrs, ka)
+			}
+		}
+		if len(addrs) > 0 {
+			result = append(result, addrs)
+		}
+	}
+	return result
+}
+
+// sample returns up to max random addresses that aren't terrible, what we answer getAddrs with.
+func (am *addrManager) sample(max int) []string {
+	now := time.Now()
+
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	addrs := make([]string, 0, len(am.addrs))
+	for _, ka := range am.addrs {
+		if !ka.terrible(now) {
+			addrs = append(addrs, ka.Addr)
+		}
+	}
+	am.rand.Shuffle(len(addrs), func(i, j int) {
+		addrs[i], addrs[j] = addrs[j], addrs[i]
+	})
+	if len(addrs) > max {
+		addrs = addrs[:max]
+	}
+	return addrs
+}
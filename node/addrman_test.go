@@ -0,0 +1,124 @@
+package node
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddrManagerTriedAndPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), addrFileName)
+	am := newAddrManager(path)
+
+	assert.Equal(t, 2, am.add([]string{"10.0.0.1:3000", "10.1.0.1:3000", ":3000", "10.2.0.1", "nonsense"}, "10.9.0.1:3000"))
+	assert.Equal(t, 0, am.add([]string{"10.0.0.1:3000"}, "10.9.0.2:3000"))
+	assert.Equal(t, 2, am.len())
+
+	am.attempt("10.0.0.1:3000")
+	am.good("10.0.0.1:3000")
+	am.attempt("10.1.0.1:3000") // Failed, we don't try it again right away.
+	addr, ok := am.pick(func(string) bool { return false })
+	require.True(t, ok)
+	assert.Equal(t, "10.0.0.1:3000", addr)
+	_, ok = am.pick(func(addr string) bool { return addr == "10.0.0.1:3000" })
+	assert.False(t, ok)
+
+	require.Nil(t, am.save())
+	loaded := newAddrManager(path)
+	require.Nil(t, loaded.load())
+	assert.Equal(t, am.key, loaded.key)
+	assert.Equal(t, 2, loaded.len())
+	assert.True(t, loaded.addrs["10.0.0.1:3000"].Tried)
+	assert.False(t, loaded.addrs["10.1.0.1:3000"].Tried)
+	assert.Equal(t, 1, loaded.addrs["10.1.0.1:3000"].Failures)
+	assert.Equal(t, "10.9.0.1:3000", loaded.addrs["10.1.0.1:3000"].Source)
+}
+
+func TestAddrManagerLimitsWhatOneSourceGroupFills(t *testing.T) {
+	am := newAddrManager("")
+
+	// An attacker with a lot of nodes in one network sends us lots of addresses.
+	for i := 0; i < 10; i++ {
+		addrs := make([]string, maxAddrsPerMessage)
+		for j := range addrs {
+			addrs[j] = fmt.Sprintf("66.%d.%d.%d:3000", i, j/256, j%256)
+		}
+		am.add(addrs, fmt.Sprintf("66.6.0.%d:3000", i))
+	}
+	buckets := map[int]bool{}
+	for _, ka := range am.addrs {
+		buckets[ka.bucket] = true
+	}
+	assert.LessOrEqual(t, len(buckets), newBucketsPerSourceGroup)
+	assert.LessOrEqual(t, am.len(), newBucketsPerSourceGroup*addrBucketSize)
+
+	// Somebody else can still tell us about the rest of the network.
+	honest := []string{"1.2.3.4:3000", "5.6.7.8:3000", "9.10.11.12:3000"}
+	assert.Equal(t, len(honest), am.add(honest, "20.0.0.1:3000"))
+	for _, addr := range honest {
+		assert.Contains(t, am.addrs, addr)
+	}
+	assert.Len(t, am.sample(10), 10)
+}
+
+func TestNodesDiscoverPeersWithGetAddrs(t *testing.T) {
+	var (
+		addrA = freeAddr(t)
+		addrB = freeAddr(t)
+		addrC = freeAddr(t)
+		dir   = t.TempDir()
+		a     = NewNode(ServerConfig{})
+		b     = NewNode(ServerConfig{})
+		c     = NewNode(ServerConfig{DataDir: dir})
+	)
+	defer a.Stop()
+	defer b.Stop()
+
+	go a.Start(addrA, nil)
+	waitForListener(t, addrA)
+	go b.Start(addrB, []string{addrA})
+	require.Eventually(t, func() bool {
+		return len(a.getPeerList()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// c only knows a, a tells it about b.
+	go c.Start(addrC, []string{addrA})
+	require.Eventually(t, func() bool {
+		return len(c.getPeerList()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, []string{addrA, addrB}, c.getPeerList())
+
+	c.Stop()
+	saved := newAddrManager(filepath.Join(dir, addrFileName))
+	require.Nil(t, saved.load())
+	assert.True(t, saved.addrs[addrA].Tried)
+	assert.True(t, saved.addrs[addrB].Tried)
+}
+
+func TestInboundAddrsAreSourcedByTheirIP(t *testing.T) {
+	var (
+		n   = NewNode(ServerConfig{ListenAddr: "127.0.0.1:3000"})
+		ctx = guardContext("10.0.0.9", "")
+	)
+	// One host claiming addresses all over the internet.
+	for _, addr := range []string{"1.2.3.4:3000", "5.6.7.8:3000", "9.10.11.12:3000"} {
+		_, _, err := connectPipe(t, ctx, n, remoteVersion(n, crypto.GeneratePrivateKey(), addr))
+		require.Nil(t, err)
+	}
+	// We remember the address right after we answered the handshake.
+	require.Eventually(t, func() bool {
+		n.addrs.lock.Lock()
+		defer n.addrs.lock.Unlock()
+		return len(n.addrs.addrs) == 3
+	}, time.Second, 10*time.Millisecond)
+	n.addrs.lock.Lock()
+	defer n.addrs.lock.Unlock()
+	for addr, ka := range n.addrs.addrs {
+		assert.Equal(t, "10.0.0.9", ka.Source, addr)
+	}
+}
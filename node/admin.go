@@ -0,0 +1,74 @@
+package node
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/Fito305/blocker/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The AdminAPI is the grpc service for the operator of the node. Unlike the PublicAPI it can
+// change what the node does, so keep its listener on localhost and set an AdminToken.
+
+func (n *Node) startAdminServer(opts ...grpc.ServerOption) error {
+	ln, err := net.Listen("tcp", n.AdminListenAddr)
+	if err != nil {
+		return err
+	}
+	n.logger.Infow("admin api started...", "port", n.AdminListenAddr)
+	return n.newAdminServer(opts...).Serve(ln)
+}
+
+func (n *Node) newAdminServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(newRPCGuard(n.AdminToken, RateLimit{}).serverOptions(), opts...)
+	server := grpc.NewServer(opts...)
+	proto.RegisterAdminAPIServer(server, n)
+	n.onStop(server.Stop)
+	return server
+}
+
+func (n *Node) ListBans(ctx context.Context, req *proto.ListBansRequest) (*proto.BanList, error) {
+	resp := &proto.BanList{}
+	for _, ban := range n.bans.list() {
+		resp.Bans = append(resp.Bans, &proto.BanInfo{
+			Address: ban.Address,
+			Until:   ban.Until.Unix(),
+			Reason:  ban.Reason,
+		})
+	}
+	return resp, nil
+}
+
+func (n *Node) Ban(ctx context.Context, req *proto.BanRequest) (*proto.Ack, error) {
+	if req.Address == "" {
+		return nil, status.Error(codes.InvalidArgument, "address is required")
+	}
+	if req.Duration < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "duration cannot be negative, got (%d)", req.Duration)
+	}
+	reason := req.Reason
+	if reason == "" {
+		reason = "banned by the operator"
+	}
+	if err := n.bans.ban(req.Address, time.Duration(req.Duration)*time.Second, reason); err != nil {
+		return nil, err
+	}
+	n.logger.Infow("banned peer", "address", banAddress(req.Address), "reason", reason)
+	n.disconnectBanned()
+	return &proto.Ack{}, nil
+}
+
+func (n *Node) Unban(ctx context.Context, req *proto.UnbanRequest) (*proto.Ack, error) {
+	if req.Address == "" {
+		return nil, status.Error(codes.InvalidArgument, "address is required")
+	}
+	if err := n.bans.unban(req.Address); err != nil {
+		return nil, err
+	}
+	n.logger.Infow("unbanned peer", "address", banAddress(req.Address))
+	return &proto.Ack{}, nil
+}
@@ -0,0 +1,456 @@
+package node
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	"golang.org/x/net/websocket"
+)
+
+// The JSON API is how wallets and other programs talk to a node. It runs next to the
+// grpc server on its own port (ServerConfig.APIListenAddr), with the token and rate limit
+// of the PublicAPI. Hashes, keys and addresses are always hex encoded.
+
+const maxTxBodySize = 1 << 20
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type apiFunc func(w http.ResponseWriter, r *http.Request) error
+
+// An apiStatusError lets a handler choose the status code it fails with.
+type apiStatusError struct {
+	status int
+	err    error
+}
+
+func (e apiStatusError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(format string, args ...any) error {
+	return apiStatusError{status: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+func notFound(err error) error {
+	return apiStatusError{status: http.StatusNotFound, err: err}
+}
+
+func makeHTTPHandler(fn apiFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := fn(w, r); err != nil {
+			status := http.StatusInternalServerError
+			if e, ok := err.(apiStatusError); ok {
+				status = e.status
+			}
+			writeJSON(w, status, apiError{Error: err.Error()})
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+type TxStatusJSON struct {
+	Transaction   *types.TransactionJSON `json:"transaction,omitempty"`
+	Status        string                 `json:"status"`
+	BlockHash     string                 `json:"blockHash,omitempty"`
+	Height        int                    `json:"height,omitempty"`
+	Index         int                    `json:"index,omitempty"`
+	Confirmations int                    `json:"confirmations"`
+}
+
+func NewTxStatusJSON(status *proto.TransactionStatus) *TxStatusJSON {
+	resp := &TxStatusJSON{
+		Status:        status.Status.String(),
+		Height:        int(status.Height),
+		Index:         int(status.Index),
+		Confirmations: int(status.Confirmations),
+	}
+	if status.Transaction != nil {
+		resp.Transaction = types.NewTransactionJSON(status.Transaction)
+	}
+	if len(status.BlockHash) > 0 {
+		resp.BlockHash = hex.EncodeToString(status.BlockHash)
+	}
+	return resp
+}
+
+type AnchorJSON struct {
+	ContentHash   string `json:"contentHash"`
+	BlockHash     string `json:"blockHash"`
+	Height        int    `json:"height"`
+	TxHash        string `json:"txHash"`
+	OutIndex      int    `json:"outIndex"`
+	Confirmations int    `json:"confirmations"`
+}
+
+type UTXOJSON struct {
+	TxHash   string `json:"txHash"`
+	OutIndex int    `json:"outIndex"`
+	Amount   uint64 `json:"amount"`
+	Asset    string `json:"asset,omitempty"`
+}
+
+type HistoryEntryJSON struct {
+	TxHash string `json:"txHash"`
+	Height int    `json:"height"`
+	Asset  string `json:"asset,omitempty"`
+	Amount int64  `json:"amount"`
+}
+
+type EventJSON struct {
+	Topic       string                 `json:"topic"`
+	Height      int                    `json:"height"`
+	Hash        string                 `json:"hash,omitempty"`
+	Block       *types.BlockJSON       `json:"block,omitempty"`
+	Transaction *types.TransactionJSON `json:"transaction,omitempty"`
+	Address     string                 `json:"address,omitempty"`
+	Amount      uint64                 `json:"amount,omitempty"`
+	Asset       string                 `json:"asset,omitempty"`
+	Peer        string                 `json:"peer,omitempty"`
+}
+
+func NewEventJSON(e *proto.Event) *EventJSON {
+	event := &EventJSON{
+		Topic:   e.Topic,
+		Height:  int(e.Height),
+		Hash:    hex.EncodeToString(e.Hash),
+		Address: hex.EncodeToString(e.Address),
+		Amount:  e.Amount,
+		Asset:   hex.EncodeToString(e.Asset),
+		Peer:    e.Peer,
+	}
+	if e.Block != nil {
+		event.Block = types.NewBlockJSON(e.Block)
+	}
+	if e.Transaction != nil {
+		event.Transaction = types.NewTransactionJSON(e.Transaction)
+	}
+	return event
+}
+
+type StatusJSON struct {
+	Version      string   `json:"version"`
+	ListenAddr   string   `json:"listenAddr"`
+	Height       int      `json:"height"`
+	BestHash     string   `json:"bestHash"`
+	LowestHeight int      `json:"lowestHeight"`
+	Pruned       bool     `json:"pruned"`
+	Peers        []string `json:"peers"`
+	MempoolSize  int      `json:"mempoolSize"`
+	Validator    bool     `json:"validator"`
+}
+
+func (n *Node) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", makeHTTPHandler(n.handleGetStatus))
+	mux.HandleFunc("POST /tx", makeHTTPHandler(n.handlePostTx))
+	mux.HandleFunc("GET /tx/{hash}", makeHTTPHandler(n.handleGetTx))
+	mux.HandleFunc("GET /block/hash/{hash}", makeHTTPHandler(n.handleGetBlockByHash))
+	mux.HandleFunc("GET /block/height/{height}", makeHTTPHandler(n.handleGetBlockByHeight))
+	mux.HandleFunc("GET /address/{address}/balance", makeHTTPHandler(n.handleGetBalance))
+	mux.HandleFunc("GET /address/{address}/utxos", makeHTTPHandler(n.handleGetUTXOs))
+	mux.HandleFunc("GET /address/{address}/history", makeHTTPHandler(n.handleGetHistory))
+	mux.HandleFunc("GET /mempool", makeHTTPHandler(n.handleGetMempool))
+	mux.HandleFunc("GET /anchor/{contentHash}", makeHTTPHandler(n.handleGetAnchor))
+	mux.Handle("GET /ws", websocket.Server{
+		Handler:   n.handleWebSocket,
+		Handshake: n.checkOrigin,
+	})
+	return newRPCGuard(n.PublicToken, n.PublicRateLimit).httpHandler(mux)
+}
+
+// checkOrigin keeps other websites from subscribing through the browser of somebody who can reach us.
+// Programs don't send an Origin, browsers only get in from our own origin or the APIOrigins.
+func (n *Node) checkOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	config.Origin = u
+	if u.Host == r.Host {
+		return nil
+	}
+	for _, allowed := range n.APIOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), strings.TrimSuffix(origin, "/")) {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %s not allowed", origin)
+}
+
+func (n *Node) startAPIServer(tlsConfig *tls.Config) error {
+	server := &http.Server{
+		Addr:              n.APIListenAddr,
+		Handler:           n.apiHandler(),
+		ReadHeaderTimeout: 5 * time.Second,
+		TLSConfig:         tlsConfig,
+	}
+	n.onStop(func() { server.Close() })
+	n.logger.Infow("api server started...", "port", n.APIListenAddr, "tls", tlsConfig != nil)
+	var err error
+	if tlsConfig != nil {
+		err = server.ListenAndServeTLS("", "") // The certificate is in the TLSConfig.
+	} else {
+		err = server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil // We stopped.
+	}
+	return err
+}
+
+func (n *Node) handleGetStatus(w http.ResponseWriter, r *http.Request) error {
+	tip, err := n.chain.GetBlockByHeight(n.chain.Height())
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, StatusJSON{
+		Version:      n.Version,
+		ListenAddr:   n.ListenAddr,
+		Height:       n.chain.Height(),
+		BestHash:     hex.EncodeToString(types.HashBlock(tip)),
+		LowestHeight: n.chain.LowestHeight(),
+		Pruned:       n.chain.IsPruned(),
+		Peers:        n.getPeerList(),
+		MempoolSize:  n.mempool.Len(),
+		Validator:    n.PrivateKey != nil,
+	})
+}
+
+func (n *Node) handlePostTx(w http.ResponseWriter, r *http.Request) error {
+	var txJSON types.TransactionJSON
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTxBodySize)).Decode(&txJSON); err != nil {
+		return badRequest("invalid json: %s", err)
+	}
+	tx, err := txJSON.Proto()
+	if err != nil {
+		return badRequest("invalid tx: %s", err)
+	}
+	if err := n.submitTransaction(tx); err != nil {
+		return badRequest("invalid tx: %s", err)
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{
+		"hash": hex.EncodeToString(types.HashTransaction(tx)),
+	})
+}
+
+// submitTransaction validates a transaction from a client before it goes into the mempool
+// and out to our peers. Unlike our peers, clients don't get the benefit of the doubt.
+func (n *Node) submitTransaction(tx *proto.Transaction) error {
+	if err := n.chain.ValidateTransaction(tx); err != nil {
+		return err
+	}
+	if n.mempool.Add(tx) {
+		n.announce(tx)
+	}
+	return nil
+}
+
+func (n *Node) handleGetTx(w http.ResponseWriter, r *http.Request) error {
+	hash, err := hex.DecodeString(r.PathValue("hash"))
+	if err != nil {
+		return badRequest("invalid tx hash")
+	}
+	status, err := n.GetTransaction(r.Context(), &proto.GetTransactionRequest{Hash: hash})
+	if err != nil {
+		return err
+	}
+	if status.Status == proto.TxStatus_TX_UNKNOWN {
+		return notFound(fmt.Errorf("tx [%s] not found", r.PathValue("hash")))
+	}
+	return writeJSON(w, http.StatusOK, NewTxStatusJSON(status))
+}
+
+func (n *Node) handleGetBlockByHash(w http.ResponseWriter, r *http.Request) error {
+	hash, err := hex.DecodeString(r.PathValue("hash"))
+	if err != nil {
+		return badRequest("invalid block hash")
+	}
+	block, err := n.chain.GetBlockByHash(hash)
+	if err != nil {
+		return notFound(err)
+	}
+	return writeJSON(w, http.StatusOK, types.NewBlockJSON(block))
+}
+
+func (n *Node) handleGetBlockByHeight(w http.ResponseWriter, r *http.Request) error {
+	height, err := strconv.Atoi(r.PathValue("height"))
+	if err != nil || height < 0 {
+		return badRequest("invalid block height")
+	}
+	block, err := n.chain.GetBlockByHeight(height)
+	if err != nil {
+		return notFound(err)
+	}
+	return writeJSON(w, http.StatusOK, types.NewBlockJSON(block))
+}
+
+func (n *Node) handleGetAnchor(w http.ResponseWriter, r *http.Request) error {
+	contentHash, err := hex.DecodeString(r.PathValue("contentHash"))
+	if err != nil {
+		return badRequest("invalid content hash")
+	}
+	anchor, err := n.chain.GetAnchor(contentHash)
+	if err != nil {
+		return notFound(err)
+	}
+	return writeJSON(w, http.StatusOK, AnchorJSON{
+		ContentHash:   anchor.ContentHash,
+		BlockHash:     anchor.BlockHash,
+		Height:        anchor.Height,
+		TxHash:        anchor.TxHash,
+		OutIndex:      anchor.OutIndex,
+		Confirmations: n.chain.Confirmations(anchor.Height),
+	})
+}
+
+func (n *Node) handleGetBalance(w http.ResponseWriter, r *http.Request) error {
+	address, err := hex.DecodeString(r.PathValue("address"))
+	if err != nil {
+		return badRequest("invalid address")
+	}
+	balance, err := n.chain.GetBalance(address)
+	if err != nil {
+		return err
+	}
+	// The native coin is under "native", the other assets under their asset ID.
+	resp := map[string]uint64{}
+	for asset, amount := range balance {
+		if asset == NativeAsset {
+			asset = "native"
+		}
+		resp[asset] = amount
+	}
+	return writeJSON(w, http.StatusOK, resp)
+}
+
+func (n *Node) handleGetUTXOs(w http.ResponseWriter, r *http.Request) error {
+	address, err := hex.DecodeString(r.PathValue("address"))
+	if err != nil {
+		return badRequest("invalid address")
+	}
+	utxos, err := n.chain.GetUTXOs(address)
+	if err != nil {
+		return err
+	}
+	resp := make([]*UTXOJSON, len(utxos))
+	for i, utxo := range utxos {
+		resp[i] = &UTXOJSON{
+			TxHash:   utxo.Hash,
+			OutIndex: utxo.OutIndex,
+			Amount:   utxo.Amount,
+			Asset:    utxo.Asset,
+		}
+	}
+	return writeJSON(w, http.StatusOK, resp)
+}
+
+func (n *Node) handleGetHistory(w http.ResponseWriter, r *http.Request) error {
+	address, err := hex.DecodeString(r.PathValue("address"))
+	if err != nil {
+		return badRequest("invalid address")
+	}
+	if n.history == nil {
+		return notFound(fmt.Errorf("address index is not enabled on this node"))
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	entries, total := n.history.GetHistory(address, offset, limit)
+	resp := struct {
+		Entries []*HistoryEntryJSON `json:"entries"`
+		Total   int                 `json:"total"`
+	}{
+		Entries: make([]*HistoryEntryJSON, len(entries)),
+		Total:   total,
+	}
+	for i, entry := range entries {
+		resp.Entries[i] = &HistoryEntryJSON{
+			TxHash: entry.TxHash,
+			Height: entry.Height,
+			Asset:  entry.Asset,
+			Amount: entry.Amount,
+		}
+	}
+	return writeJSON(w, http.StatusOK, resp)
+}
+
+// handleWebSocket streams events as JSON messages. Filter them with the query,
+// /ws?topics=block,address&addresses=<hex>,<hex>
+func (n *Node) handleWebSocket(ws *websocket.Conn) {
+	defer ws.Close()
+
+	var (
+		query     = ws.Request().URL.Query()
+		topics    []string
+		addresses [][]byte
+	)
+	if t := query.Get("topics"); t != "" {
+		topics = strings.Split(t, ",")
+	}
+	if a := query.Get("addresses"); a != "" {
+		for _, s := range strings.Split(a, ",") {
+			address, err := hex.DecodeString(s)
+			if err != nil {
+				websocket.JSON.Send(ws, apiError{Error: "invalid address " + s})
+				return
+			}
+			addresses = append(addresses, address)
+		}
+	}
+
+	sub := n.events.Subscribe(NewEventFilter(topics, addresses))
+	defer n.events.Unsubscribe(sub)
+
+	// We never expect anything from the client, reading only tells us when it went away.
+	closed := make(chan struct{})
+	go func() {
+		var msg []byte
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+		close(closed)
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				websocket.JSON.Send(ws, apiError{Error: "subscription dropped, too slow to keep up with events"})
+				return
+			}
+			if err := websocket.JSON.Send(ws, NewEventJSON(e)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (n *Node) handleGetMempool(w http.ResponseWriter, r *http.Request) error {
+	txx := n.mempool.List()
+	resp := make([]*types.TransactionJSON, len(txx))
+	for i, tx := range txx {
+		resp[i] = types.NewTransactionJSON(tx)
+	}
+	return writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,195 @@
+package node
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestAPISubmitAndGetTx(t *testing.T) {
+	var (
+		n         = NewNode(ServerConfig{ListenAddr: ":3000"})
+		server    = httptest.NewServer(n.apiHandler())
+		recipient = crypto.GeneratePrivateKey().Public().Address().Bytes()
+		tx        = spendGenesisTx(t, n.chain, recipient, 100)
+		hash      = hex.EncodeToString(types.HashTransaction(tx))
+	)
+	defer server.Close()
+
+	body, err := json.Marshal(types.NewTransactionJSON(tx))
+	require.Nil(t, err)
+	resp, err := http.Post(server.URL+"/tx", "application/json", bytes.NewReader(body))
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, n.mempool.Len())
+
+	resp, err = http.Get(server.URL + "/tx/" + hash)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var status TxStatusJSON
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&status))
+	assert.Equal(t, "TX_PENDING", status.Status)
+	assert.Equal(t, hash, status.Transaction.Hash)
+
+	// The signature no longer matches once we change the amount.
+	tx.Outputs[0].Amount = 1000
+	body, err = json.Marshal(types.NewTransactionJSON(tx))
+	require.Nil(t, err)
+	resp, err = http.Post(server.URL+"/tx", "application/json", bytes.NewReader(body))
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAPIGetBlockAndBalance(t *testing.T) {
+	var (
+		n      = NewNode(ServerConfig{ListenAddr: ":3000"})
+		server = httptest.NewServer(n.apiHandler())
+	)
+	defer server.Close()
+
+	genesis, err := n.chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	hash := hex.EncodeToString(types.HashBlock(genesis))
+
+	for _, path := range []string{"/block/height/0", "/block/hash/" + hash} {
+		resp, err := http.Get(server.URL + path)
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var block types.BlockJSON
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&block))
+		assert.Equal(t, hash, block.Hash)
+		assert.Equal(t, 1, len(block.Transactions))
+	}
+
+	resp, err := http.Get(server.URL + "/block/height/1")
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, err = http.Get(server.URL + "/block/hash/nothex")
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	godAddr := devKey().Public().Address()
+	resp, err = http.Get(server.URL + "/address/" + godAddr.String() + "/balance")
+	require.Nil(t, err)
+	var balance map[string]uint64
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&balance))
+	assert.Equal(t, map[string]uint64{"native": 1000}, balance)
+
+	resp, err = http.Get(server.URL + "/status")
+	require.Nil(t, err)
+	var status StatusJSON
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&status))
+	assert.Equal(t, 0, status.Height)
+	assert.Equal(t, hash, status.BestHash)
+}
+
+func TestAPIWebSocket(t *testing.T) {
+	var (
+		n      = NewNode(ServerConfig{ListenAddr: ":3000"})
+		server = httptest.NewServer(n.apiHandler())
+	)
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?topics=tx", "", server.URL)
+	require.Nil(t, err)
+	defer ws.Close()
+
+	// Wait until the handler subscribed before we add the tx.
+	require.Eventually(t, func() bool {
+		n.events.lock.Lock()
+		defer n.events.lock.Unlock()
+		return len(n.events.subs) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	tx := spendGenesisTx(t, n.chain, crypto.GeneratePrivateKey().Public().Address().Bytes(), 1)
+	require.Nil(t, n.submitTransaction(tx))
+
+	var event EventJSON
+	require.Nil(t, websocket.JSON.Receive(ws, &event))
+	assert.Equal(t, TopicTx, event.Topic)
+	assert.Equal(t, hex.EncodeToString(types.HashTransaction(tx)), event.Hash)
+}
+
+func TestAPITokenAndRateLimit(t *testing.T) {
+	var (
+		n = NewNode(ServerConfig{
+			ListenAddr:      ":3000",
+			PublicToken:     "secret",
+			PublicRateLimit: RateLimit{PerSecond: 0.001, Burst: 2},
+		})
+		server = httptest.NewServer(n.apiHandler())
+	)
+	defer server.Close()
+
+	get := func(token string) int {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/status", nil)
+		require.Nil(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusUnauthorized, get(""))
+	assert.Equal(t, http.StatusUnauthorized, get("wrong"))
+	assert.Equal(t, http.StatusOK, get("secret"))
+	assert.Equal(t, http.StatusOK, get("secret"))
+	assert.Equal(t, http.StatusTooManyRequests, get("secret"))
+}
+
+func TestAPIWebSocketOrigin(t *testing.T) {
+	var (
+		n      = NewNode(ServerConfig{ListenAddr: ":3000", APIOrigins: []string{"https://wallet.example"}})
+		server = httptest.NewServer(n.apiHandler())
+		url    = "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	)
+	defer server.Close()
+
+	_, err := websocket.Dial(url, "", "https://evil.example")
+	assert.NotNil(t, err)
+
+	ws, err := websocket.Dial(url, "", "https://wallet.example")
+	require.Nil(t, err)
+	ws.Close()
+}
+
+func TestAPIGetAnchor(t *testing.T) {
+	var (
+		n      = NewNode(ServerConfig{ListenAddr: ":3000"})
+		server = httptest.NewServer(n.apiHandler())
+	)
+	defer server.Close()
+
+	// The genesis block anchors the hash of the genesis file.
+	genesis, err := n.chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	outputs := genesis.Transactions[0].Outputs
+	contentHash := hex.EncodeToString(types.HashData(outputs[len(outputs)-1].Data))
+
+	resp, err := http.Get(server.URL + "/anchor/" + contentHash)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var anchor AnchorJSON
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&anchor))
+	assert.Equal(t, contentHash, anchor.ContentHash)
+	assert.Equal(t, hex.EncodeToString(types.HashBlock(genesis)), anchor.BlockHash)
+	assert.Equal(t, len(outputs)-1, anchor.OutIndex)
+	assert.Equal(t, 1, anchor.Confirmations)
+
+	resp, err = http.Get(server.URL + "/anchor/" + hex.EncodeToString(types.HashData([]byte("nothing"))))
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
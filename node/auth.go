@@ -0,0 +1,207 @@
+package node
+
+import (
+	"container/list"
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Both grpc services and the JSON API run behind an rpcGuard, each with its own token and rate limit.
+// A token is sent as "authorization: Bearer <token>" in the metadata of every call, or in the
+// Authorization header of every request of the JSON API.
+
+const maxRateLimiters = 10000
+
+type RateLimit struct {
+	PerSecond float64 `json:"perSecond"` // 0 means no limit.
+	Burst     int     `json:"burst"`
+}
+
+type rpcGuard struct {
+	token string // empty means no token needed.
+	limit RateLimit
+
+	// Both optional, the PeerService bans peers that misbehave.
+	isBanned      func(ip string) bool
+	onRateLimited func(ip string)
+
+	lock        sync.Mutex
+	maxLimiters int
+	limiters    map[string]*list.Element // remote ip => its element in lru
+	lru         *list.List               // *ipLimiter, the ip we heard from last in front.
+}
+
+type ipLimiter struct {
+	ip      string
+	limiter *rate.Limiter
+}
+
+func newRPCGuard(token string, limit RateLimit) *rpcGuard {
+	return &rpcGuard{
+		token:       token,
+		limit:       limit,
+		maxLimiters: maxRateLimiters,
+		limiters:    make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+}
+
+func (g *rpcGuard) serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(g.unaryInterceptor),
+		grpc.StreamInterceptor(g.streamInterceptor),
+	}
+}
+
+func (g *rpcGuard) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := g.check(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (g *rpcGuard) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := g.check(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func (g *rpcGuard) check(ctx context.Context) error {
+	if err := g.checkToken(tokenFromContext(ctx)); err != nil {
+		return err
+	}
+	return g.allow(remoteIP(ctx))
+}
+
+// httpHandler runs the same checks on every request before next gets it.
+func (g *rpcGuard) httpHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := g.checkToken(bearerToken(r.Header.Values("Authorization")))
+		if err == nil {
+			err = g.allow(httpRemoteIP(r))
+		}
+		if err != nil {
+			writeJSON(w, httpStatus(status.Code(err)), apiError{Error: status.Convert(err).Message()})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (g *rpcGuard) checkToken(token string) error {
+	// In constant time, how long a wrong token takes to reject tells nothing about the right one.
+	if g.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(g.token)) != 1 {
+		return status.Error(codes.Unauthenticated, "invalid or missing token")
+	}
+	return nil
+}
+
+// allow checks a call or, over a stream, a message from ip.
+func (g *rpcGuard) allow(ip string) error {
+	if g.isBanned != nil && g.isBanned(ip) {
+		return status.Error(codes.PermissionDenied, "banned")
+	}
+	if g.limit.PerSecond > 0 && !g.limiter(ip).Allow() {
+		if g.onRateLimited != nil {
+			g.onRateLimited(ip)
+		}
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return nil
+}
+
+func (g *rpcGuard) limiter(ip string) *rate.Limiter {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if e, ok := g.limiters[ip]; ok {
+		g.lru.MoveToFront(e)
+		return e.Value.(*ipLimiter).limiter
+	}
+	// Don't let somebody with a lot of addresses fill up our memory. We forget the ip we haven't heard from
+	// the longest, whoever is flooding us right now keeps its limiter.
+	if g.lru.Len() >= g.maxLimiters {
+		oldest := g.lru.Remove(g.lru.Back()).(*ipLimiter)
+		delete(g.limiters, oldest.ip)
+	}
+	burst := g.limit.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	l := rate.NewLimiter(rate.Limit(g.limit.PerSecond), burst)
+	g.limiters[ip] = g.lru.PushFront(&ipLimiter{ip: ip, limiter: l})
+	return l
+}
+
+func tokenFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	return bearerToken(md.Get("authorization"))
+}
+
+func bearerToken(values []string) string {
+	for _, v := range values {
+		if token, ok := strings.CutPrefix(v, "Bearer "); ok {
+			return token
+		}
+	}
+	return ""
+}
+
+func remoteIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func httpRemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// tokenCredentials sends our token along with every call we make to a peer.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
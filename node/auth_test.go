@@ -0,0 +1,73 @@
+package node
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func guardContext(ip string, token string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000},
+	})
+	if token != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+	}
+	return ctx
+}
+
+func TestRPCGuardToken(t *testing.T) {
+	guard := newRPCGuard("secret", RateLimit{})
+	assert.Nil(t, guard.check(guardContext("10.0.0.1", "secret")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(guard.check(guardContext("10.0.0.1", "wrong"))))
+	assert.Equal(t, codes.Unauthenticated, status.Code(guard.check(guardContext("10.0.0.1", ""))))
+
+	assert.Nil(t, newRPCGuard("", RateLimit{}).check(guardContext("10.0.0.1", "")))
+}
+
+func TestRPCGuardRateLimit(t *testing.T) {
+	guard := newRPCGuard("", RateLimit{PerSecond: 0.001, Burst: 2})
+	assert.Nil(t, guard.check(guardContext("10.0.0.1", "")))
+	assert.Nil(t, guard.check(guardContext("10.0.0.1", "")))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(guard.check(guardContext("10.0.0.1", ""))))
+
+	// Every ip has its own limit.
+	assert.Nil(t, guard.check(guardContext("10.0.0.2", "")))
+}
+
+func TestRPCGuardBansAndFlooding(t *testing.T) {
+	var (
+		bans  = newBanList("", 2, time.Hour)
+		guard = newRPCGuard("", RateLimit{PerSecond: 0.001, Burst: 1})
+	)
+	guard.isBanned = bans.isBanned
+	guard.onRateLimited = func(ip string) {
+		bans.misbehaving(ip, scoreRateLimited, "flooding us")
+	}
+	assert.Nil(t, guard.check(guardContext("10.0.0.1", "")))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(guard.check(guardContext("10.0.0.1", ""))))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(guard.check(guardContext("10.0.0.1", ""))))
+	assert.Equal(t, codes.PermissionDenied, status.Code(guard.check(guardContext("10.0.0.1", ""))))
+	assert.Nil(t, guard.check(guardContext("10.0.0.2", "")))
+}
+
+func TestRPCGuardForgetsTheLeastRecentIP(t *testing.T) {
+	guard := newRPCGuard("", RateLimit{PerSecond: 0.001, Burst: 1})
+	guard.maxLimiters = 2
+	assert.Nil(t, guard.check(guardContext("10.0.0.1", "")))
+	assert.Nil(t, guard.check(guardContext("10.0.0.2", "")))
+
+	// 10.0.0.1 keeps flooding, so 10.0.0.2 is the one forgotten for 10.0.0.3.
+	assert.Equal(t, codes.ResourceExhausted, status.Code(guard.check(guardContext("10.0.0.1", ""))))
+	assert.Nil(t, guard.check(guardContext("10.0.0.3", "")))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(guard.check(guardContext("10.0.0.1", ""))))
+	assert.Len(t, guard.limiters, 2)
+	assert.Nil(t, guard.check(guardContext("10.0.0.2", "")))
+}
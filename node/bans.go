@@ -0,0 +1,229 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Peers that misbehave collect a score by address (the host, without the port, so reconnecting from
+// another port doesn't help). Once the score reaches the threshold the address gets banned for a while.
+
+const (
+	defaultBanThreshold = 100
+	defaultBanDuration  = 24 * time.Hour
+
+	scoreInvalidTx    = 10
+	scoreInvalidBlock = 50
+	scoreInvalidVote  = 10
+	scoreRateLimited  = 1
+	scoreFlooding     = 20 // Messages bigger than the protocol allows.
+
+	banFileName = "bans.json"
+)
+
+type ban struct {
+	Address string    `json:"address"`
+	Until   time.Time `json:"until"`
+	Reason  string    `json:"reason"`
+}
+
+type banList struct {
+	threshold int
+	duration  time.Duration
+	path      string // empty means we don't keep the bans across restarts.
+
+	lock   sync.Mutex
+	scores map[string]int  // address => score
+	bans   map[string]*ban // address => ban
+}
+
+func newBanList(path string, threshold int, duration time.Duration) *banList {
+	return &banList{
+		threshold: threshold,
+		duration:  duration,
+		path:      path,
+		scores:    make(map[string]int),
+		bans:      make(map[string]*ban),
+	}
+}
+
+// banAddress returns the host of addr, "1.2.3.4:3000" and "1.2.3.4" are the same address.
+func banAddress(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// load reads the bans we wrote before we restarted. No file is fine, we never banned anybody.
+func (b *banList) load() error {
+	if b.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var bans []*ban
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return fmt.Errorf("invalid ban file %s: %w", b.path, err)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, ban := range bans {
+		b.bans[ban.Address] = ban
+	}
+	return nil
+}
+
+// save needs the lock.
+func (b *banList) save() error {
+	if b.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(b.activeBans(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(b.path, data)
+}
+
+// writeFileAtomic writes a temp file first, so a crash never leaves half a file behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// activeBans needs the lock, it drops the bans that ran out.
+func (b *banList) activeBans() []*ban {
+	now := time.Now()
+	bans := make([]*ban, 0, len(b.bans))
+	for addr, ban := range b.bans {
+		if !now.Before(ban.Until) {
+			delete(b.bans, addr)
+			continue
+		}
+		bans = append(bans, ban)
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Address < bans[j].Address
+	})
+	return bans
+}
+
+func (b *banList) isBanned(addr string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	ban, ok := b.bans[banAddress(addr)]
+	return ok && time.Now().Before(ban.Until)
+}
+
+func (b *banList) score(addr string) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.scores[banAddress(addr)]
+}
+
+// misbehaving adds score to addr and bans it once it reaches the threshold. It reports whether addr got banned.
+func (b *banList) misbehaving(addr string, score int, reason string) (bool, error) {
+	addr = banAddress(addr)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.scores[addr] += score
+	if b.scores[addr] < b.threshold {
+		return false, nil
+	}
+	delete(b.scores, addr) // After the ban they start over.
+	b.bans[addr] = &ban{
+		Address: addr,
+		Until:   time.Now().Add(b.duration),
+		Reason:  reason,
+	}
+	return true, b.save()
+}
+
+// ban bans addr for duration, 0 means the default duration.
+func (b *banList) ban(addr string, duration time.Duration, reason string) error {
+	if duration == 0 {
+		duration = b.duration
+	}
+	addr = banAddress(addr)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.scores, addr)
+	b.bans[addr] = &ban{
+		Address: addr,
+		Until:   time.Now().Add(duration),
+		Reason:  reason,
+	}
+	return b.save()
+}
+
+func (b *banList) unban(addr string) error {
+	addr = banAddress(addr)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.scores, addr)
+	delete(b.bans, addr)
+	return b.save()
+}
+
+func (b *banList) list() []*ban {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.activeBans()
+}
+
+// misbehaving adds score to the address of a peer, a peer that gets banned is disconnected right away.
+func (n *Node) misbehaving(addr string, score int, reason string) {
+	if addr == "" {
+		return // Not a remote call, nobody to blame.
+	}
+	banned, err := n.bans.misbehaving(addr, score, reason)
+	if err != nil {
+		n.logger.Errorw("could not save ban list", "err", err)
+	}
+	if banned {
+		n.logger.Infow("banned peer", "address", banAddress(addr), "reason", reason, "for", n.BanDuration)
+		n.disconnectBanned()
+	}
+}
+
+// disconnectBanned drops the peers we are connected to that got banned, by the ip they connected from or by
+// the address they claim, the operator only sees the latter.
+func (n *Node) disconnectBanned() {
+	for _, p := range n.peerList() {
+		if n.bans.isBanned(p.banAddr()) || n.bans.isBanned(p.version.ListenAddr) {
+			n.removePeer(p, "banned")
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package node
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	"github.com/Fito305/blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBanListThresholdAndPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), banFileName)
+	bans := newBanList(path, 20, time.Hour)
+
+	banned, err := bans.misbehaving("10.0.0.1:3000", 10, "invalid tx")
+	require.Nil(t, err)
+	assert.False(t, banned)
+	assert.Equal(t, 10, bans.score("10.0.0.1"))
+
+	// Another port is the same address.
+	banned, err = bans.misbehaving("10.0.0.1:4000", 10, "invalid tx")
+	require.Nil(t, err)
+	assert.True(t, banned)
+	assert.True(t, bans.isBanned("10.0.0.1:5000"))
+	assert.False(t, bans.isBanned("10.0.0.2"))
+	assert.Zero(t, bans.score("10.0.0.1"))
+
+	require.Nil(t, bans.ban("10.0.0.3", time.Millisecond, "testing"))
+	time.Sleep(5 * time.Millisecond)
+	assert.False(t, bans.isBanned("10.0.0.3"))
+
+	loaded := newBanList(path, 20, time.Hour)
+	require.Nil(t, loaded.load())
+	require.Len(t, loaded.list(), 1)
+	assert.Equal(t, "10.0.0.1", loaded.list()[0].Address)
+	assert.Equal(t, "invalid tx", loaded.list()[0].Reason)
+
+	require.Nil(t, loaded.unban("10.0.0.1"))
+	assert.False(t, loaded.isBanned("10.0.0.1"))
+	reloaded := newBanList(path, 20, time.Hour)
+	require.Nil(t, reloaded.load())
+	assert.Empty(t, reloaded.list())
+}
+
+func TestInvalidTxsAndBlocksGetPeersBanned(t *testing.T) {
+	var (
+		n     = NewNode(ServerConfig{ListenAddr: "127.0.0.1:3000", BanThreshold: 100})
+		ctx   = guardContext("10.0.0.1", "")
+		other = guardContext("10.0.0.2", "")
+	)
+	// A tx spending an output that doesn't exist, the peer may know a block we don't.
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs:  []*proto.TxInput{{PrevTxHash: util.RandomHash()}},
+		Outputs: []*proto.TxOutput{{Amount: 1, Address: util.RandomHash()[:crypto.AddressLen]}},
+	}
+	privKey := crypto.GeneratePrivateKey()
+	tx.Inputs[0].PublicKey = privKey.Public().Bytes()
+	tx.Inputs[0].Signature = types.SignTransaction(privKey, tx).Bytes()
+
+	_, err := n.HandleTransaction(ctx, tx)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Zero(t, n.bans.score("10.0.0.1"))
+	assert.False(t, n.mempool.Has(tx))
+
+	// A broken signature is nobody's fault but the sender's.
+	tx.Inputs[0].Signature = tx.Inputs[0].Signature[:10]
+	_, err = n.HandleTransaction(ctx, tx)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, scoreInvalidTx, n.bans.score("10.0.0.1"))
+	assert.False(t, n.mempool.Has(tx))
+
+	// A valid tx costs nothing.
+	valid := spendGenesisTx(t, n.chain, util.RandomHash()[:crypto.AddressLen], 10)
+	_, err = n.HandleTransaction(other, valid)
+	require.Nil(t, err)
+	assert.Zero(t, n.bans.score("10.0.0.2"))
+
+	// Neither does relaying a tx whose output a block just spent.
+	block := randomBlock(t, n.chain)
+	block.Transactions = append(block.Transactions, valid)
+	signBlock(t, n.chain, privKey, block)
+	require.Nil(t, n.chain.AddBlock(block))
+	late := spendGenesisTx(t, n.chain, util.RandomHash()[:crypto.AddressLen], 20)
+	_, err = n.HandleTransaction(other, late)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Zero(t, n.bans.score("10.0.0.2"))
+
+	// A block on top of a block we don't know isn't their fault either.
+	b := randomBlock(t, n.chain)
+	b.Header.PrevHash = util.RandomHash()
+	_, err = n.HandleBlock(other, b)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Zero(t, n.bans.score("10.0.0.2"))
+
+	b = randomBlock(t, n.chain)
+	b.Header.StateRoot = util.RandomHash()
+	for i := 0; i < 2; i++ {
+		_, err = n.HandleBlock(ctx, b)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+	assert.True(t, n.bans.isBanned("10.0.0.1"))
+	assert.False(t, n.bans.isBanned("10.0.0.2"))
+
+	_, err = n.HandleBlock(other, randomBlock(t, n.chain))
+	require.Nil(t, err)
+	assert.Equal(t, 2, n.chain.Height())
+}
+
+func TestAdminBanDisconnectsPeer(t *testing.T) {
+	var (
+		n   = NewNode(ServerConfig{ListenAddr: "127.0.0.1:3000"})
+		ctx = context.Background()
+	)
+	key := crypto.GeneratePrivateKey()
+	_, err := handshake(t, ctx, n, key, "127.0.0.1:4000")
+	require.Nil(t, err)
+
+	_, err = n.Ban(ctx, &proto.BanRequest{Address: "127.0.0.1:4000", Duration: 60})
+	require.Nil(t, err)
+	assert.Empty(t, n.getPeerList())
+	bans, err := n.ListBans(ctx, &proto.ListBansRequest{})
+	require.Nil(t, err)
+	require.Len(t, bans.Bans, 1)
+	assert.Equal(t, "127.0.0.1", bans.Bans[0].Address)
+
+	_, err = handshake(t, ctx, n, key, "127.0.0.1:4000")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = n.Unban(ctx, &proto.UnbanRequest{Address: "127.0.0.1"})
+	require.Nil(t, err)
+	_, err = handshake(t, ctx, n, key, "127.0.0.1:4000")
+	require.Nil(t, err)
+}
+
+func TestPeersAreBannedByTheirIP(t *testing.T) {
+	var (
+		n        = NewNode(ServerConfig{ListenAddr: "127.0.0.1:3000", BanThreshold: 100})
+		attacker = guardContext("10.0.0.9", "")
+		victim   = guardContext("10.0.0.5", "")
+	)
+	// The attacker claims to be the node at 10.0.0.5.
+	_, stream, err := connectPipe(t, attacker, n, remoteVersion(n, crypto.GeneratePrivateKey(), "10.0.0.5:4000"))
+	require.Nil(t, err)
+	for i := 0; i < 2; i++ {
+		b := randomBlock(t, n.chain)
+		b.Header.StateRoot = util.RandomHash()
+		require.Nil(t, stream.Send(&proto.Envelope{Msg: &proto.Envelope_Block{Block: b}}))
+	}
+	require.Eventually(t, func() bool {
+		return n.bans.isBanned("10.0.0.9")
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, n.bans.isBanned("10.0.0.5"))
+	assert.Zero(t, n.bans.score("10.0.0.5"))
+	require.Eventually(t, func() bool {
+		return len(n.getPeerList()) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// Claiming yet another address doesn't get it around the ban, the real 10.0.0.5 can still connect.
+	_, _, err = connectPipe(t, attacker, n, remoteVersion(n, crypto.GeneratePrivateKey(), "10.0.0.7:4000"))
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, _, err = connectPipe(t, victim, n, remoteVersion(n, crypto.GeneratePrivateKey(), "10.0.0.5:4000"))
+	require.Nil(t, err)
+}
+
+func TestGetPeersShowsTheScoreOfTheIP(t *testing.T) {
+	var (
+		n   = NewNode(ServerConfig{ListenAddr: "127.0.0.1:3000", BanThreshold: 1000})
+		ctx = guardContext("10.0.0.9", "")
+	)
+	_, stream, err := connectPipe(t, ctx, n, remoteVersion(n, crypto.GeneratePrivateKey(), "10.0.0.5:4000"))
+	require.Nil(t, err)
+	b := randomBlock(t, n.chain)
+	b.Header.StateRoot = util.RandomHash()
+	require.Nil(t, stream.Send(&proto.Envelope{Msg: &proto.Envelope_Block{Block: b}}))
+	require.Eventually(t, func() bool {
+		return n.bans.score("10.0.0.9") == scoreInvalidBlock
+	}, time.Second, 10*time.Millisecond)
+
+	peers, err := n.GetPeers(context.Background(), &proto.PeersRequest{})
+	require.Nil(t, err)
+	require.Len(t, peers.Peers, 1)
+	assert.Equal(t, "10.0.0.5:4000", peers.Peers[0].ListenAddr)
+	assert.Equal(t, int32(scoreInvalidBlock), peers.Peers[0].Score)
+}
+
+func TestOversizedInvIsScoredAsFlooding(t *testing.T) {
+	var (
+		n   = NewNode(ServerConfig{ListenAddr: "127.0.0.1:3000"})
+		ctx = guardContext("10.0.0.9", "")
+	)
+	_, stream, err := connectPipe(t, ctx, n, remoteVersion(n, crypto.GeneratePrivateKey(), "10.0.0.9:4000"))
+	require.Nil(t, err)
+	items := make([]*proto.InvItem, maxInvItems+1)
+	for i := range items {
+		items[i] = &proto.InvItem{Type: proto.InvType_INV_TX, Hash: util.RandomHash()}
+	}
+	require.Nil(t, stream.Send(&proto.Envelope{Msg: &proto.Envelope_Inv{Inv: &proto.Inv{Items: items}}}))
+	require.Eventually(t, func() bool {
+		return n.bans.score("10.0.0.9") == scoreFlooding
+	}, time.Second, 10*time.Millisecond)
+}
@@ -3,15 +3,17 @@ package node
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math/bits"
+	"sort"
+	"sync"
 
 	"github.com/Fito305/blocker/crypto"
 	"github.com/Fito305/blocker/proto"
 	"github.com/Fito305/blocker/types"
 )
 
-const godSeed = "printedHashOnTheTerminalViafmt.PrintlnFromTheSeedVaraibleInTheFileMain.goInMainFunc" // It is to make deterministic privateKey so we can have coins or some kind of a genesis input. The output that we can use as input in our transactions.
-
 type HeaderList struct {
 	headers []*proto.Header
 }
@@ -26,6 +28,10 @@ func (list *HeaderList) Add(h *proto.Header) {
 	list.headers = append(list.headers, h)
 }
 
+func (list *HeaderList) RemoveLast() {
+	list.headers = list.headers[:len(list.headers)-1]
+}
+
 func (list *HeaderList) Get(index int) *proto.Header {
 	if index > list.Height() {
 		panic("index too high!")
@@ -46,34 +52,95 @@ type UTXO struct {
 	Hash     string
 	OutIndex int
 	Amount   uint64
+	Address  string // hex encoded address of the owner.
+	Asset    string // hex encoded asset ID, NativeAsset for the native coin.
 	Spent    bool
 }
 
+// Anchor tells us in which block and transaction some data was anchored on chain.
+type Anchor struct {
+	ContentHash string // hex encoded sha256 of the data.
+	BlockHash   string
+	Height      int
+	TxHash      string
+	OutIndex    int
+}
+
+// TxLocation tells us where a transaction is on our chain.
+type TxLocation struct {
+	BlockHash string
+	Height    int
+	Index     int // position of the tx in the block.
+}
+
+// NativeAsset is the asset key of the native coin, outputs without an asset ID.
+const NativeAsset = ""
+
+// Chain is safe to use from several goroutines. The methods that add or roll back blocks hold the lock for
+// writing, the rest read under it. They call the unlocked helpers of each other, RLock isn't reentrant.
 type Chain struct {
-	txStore    TXStorer
-	blockStore BlockStorer
-	utxoStore  UTXOStorer
-	headers    *HeaderList
+	lock        sync.RWMutex // guards the stores, the headers and everything below.
+	txStore     TXStorer
+	blockStore  BlockStorer
+	utxoStore   UTXOStorer
+	utxoSet     *utxoSet // The unspent outputs of utxoStore, for the state root.
+	anchorStore AnchorStorer
+	anchorProof map[string]*proto.SnapshotAnchor // content hash => the tx and Merkle proof of an anchor, for snapshots.
+	headers     *HeaderList
+	indexers    []Indexer
+	genesis     *Genesis
+	genesisHash []byte
+
+	undo         map[int]*blockUndo // undo data of the blocks we can still roll back, by height.
+	pruneDepth   int                // 0 keeps every block, otherwise we only keep the bodies of the last pruneDepth blocks.
+	lowestHeight int                // The lowest height we still have the block body of.
 }
 
-// Constructor
-func NewChain(bs BlockStorer, txStore TXStorer) *Chain {
+// Constructor. The genesis has to be valid (LoadGenesis and DefaultGenesis always are).
+func NewChain(bs BlockStorer, txStore TXStorer, genesis *Genesis) *Chain {
+	block, err := genesis.Block()
+	if err != nil {
+		panic(err)
+	}
 	chain := &Chain{
-		blockStore: bs,
-		txStore:    txStore,
-		utxoStore:  NewMemoryUTXOStore(), // hard code in because we will refactor this later.
-		headers:    NewHeaderList(),
+		blockStore:  bs,
+		txStore:     txStore,
+		utxoStore:   NewMemoryUTXOStore(), // hard code in because we will refactor this later.
+		utxoSet:     newUTXOSet(),
+		anchorStore: NewMemoryAnchorStore(),
+		anchorProof: make(map[string]*proto.SnapshotAnchor),
+		headers:     NewHeaderList(),
+		genesis:     genesis,
+		genesisHash: types.HashBlock(block),
+		undo:        make(map[int]*blockUndo),
+	}
+	// Create the genesis block without validation. Now we have that genesis block each time we create our new blockchain.
+	if err := chain.addBlock(block); err != nil {
+		panic(err)
 	}
-	chain.addBlock(createGenesisBlock()) // Create the genesis block without validation. Now we have that genesis block each time we create our new blockchain.
 	return chain
 }
 
+func (c *Chain) Genesis() *Genesis {
+	return c.genesis
+}
+
+// GenesisHash returns the hash of block 0, we keep it around because a pruned chain doesn't have the block anymore.
+func (c *Chain) GenesisHash() []byte {
+	return c.genesisHash
+}
+
 func (c *Chain) Height() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	return c.headers.Height()
 }
 
 func (c *Chain) AddBlock(b *proto.Block) error {
-	if err := c.ValidateBlock(b); err != nil {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.validateBlock(b); err != nil {
 		return err
 	}
 	return c.addBlock(b) // block with validation.
@@ -82,71 +149,243 @@ func (c *Chain) AddBlock(b *proto.Block) error {
 func (c *Chain) addBlock(b *proto.Block) error {
 	// Add the header to the list of headers.
 	c.headers.Add(b.Header)
+	blockHash := hex.EncodeToString(types.HashBlock(b))
+	undo := &blockUndo{}
+	c.undo[c.headers.Height()] = undo
 
-	for _, tx := range b.Transactions {
+	for i, tx := range b.Transactions {
 		// fmt.Println("NEW X: ", hex.EncodeToString(types.HashTransaction(tx)))
 		if err := c.txStore.Put(tx); err != nil {
 			return err
 		}
 		hash := hex.EncodeToString(types.HashTransaction(tx))
+		loc := &TxLocation{
+			BlockHash: blockHash,
+			Height:    c.headers.Height(),
+			Index:     i,
+		}
+		if err := c.txStore.PutLocation(hash, loc); err != nil {
+			return err
+		}
 
 		for it, output := range tx.Outputs { // We have to loop over this because we have to make it for each output.
+			if types.IsDataOutput(output) { // Data outputs cannot be spent, so we index them instead of storing a utxo.
+				anchor := &Anchor{
+					ContentHash: hex.EncodeToString(types.HashData(output.Data)),
+					BlockHash:   blockHash,
+					Height:      c.headers.Height(),
+					TxHash:      hash,
+					OutIndex:    it,
+				}
+				if _, err := c.anchorStore.Get(anchor.ContentHash); err == nil {
+					continue // Anchored before, the first time is the one that counts.
+				}
+				if err := c.anchorStore.Put(anchor); err != nil {
+					return err
+				}
+				proof, err := types.GetMerkleProof(b, types.HashTransaction(tx))
+				if err != nil {
+					return err
+				}
+				c.anchorProof[anchor.ContentHash] = &proto.SnapshotAnchor{
+					Transaction: tx,
+					OutIndex:    uint32(it),
+					Height:      int32(c.headers.Height()),
+					Proof:       proof,
+				}
+				undo.anchors = append(undo.anchors, anchor.ContentHash)
+				continue
+			}
 			utxo := &UTXO{
 				Hash:     hash,
 				Amount:   output.Amount,
 				OutIndex: it,
+				Address:  hex.EncodeToString(output.Address),
+				Asset:    hex.EncodeToString(output.Asset),
 				Spent:    false, // go will make this false by default but this is to make it more verbose.
 			}
 			if err := c.utxoStore.Put(utxo); err != nil {
 				return err
 			}
+			c.utxoSet.add(utxo)
+			undo.created = append(undo.created, utxoKey(hash, it))
 		}
 		for _, input := range tx.Inputs { // For each input we check if the tokens have been spent or not (true or false)
 			key := fmt.Sprintf("%s_%d", hex.EncodeToString(input.PrevTxHash), input.PrevOutIndex) // s is th prevHash and d is the input index of the hash		// We are going to grab the utxo transaction output from the previos tx. In this case the Genesis block.
-			fmt.Println("key --->",key) // normally this key should be a transaction.
 			utxo, err := c.utxoStore.Get(key)
 			if err != nil {
 				return err
 			}
+			spent := *utxo
+			undo.spent = append(undo.spent, &spent)
 			utxo.Spent = true
 			if err := c.utxoStore.Put(utxo); err != nil {
 				return err
 			}
-			fmt.Println("utxo --->", utxo)
-			// utxo, err = c.utxoStore.Get(key)
-			// if err != nil {
-			// 	return err
-			// }
-			// fmt.Println("utxo --->", utxo) // This block is to check if it has been stored in the utxo.
+			c.utxoSet.remove(&spent)
 		}
 	}
 	// validation
-	return c.blockStore.Put(b) // block without validation. The genisis block is not validated.
+	if err := c.blockStore.Put(b); err != nil { // block without validation. The genisis block is not validated.
+		return err
+	}
+	for _, indexer := range c.indexers {
+		if err := indexer.AddBlock(b, c.headers.Height(), undo.spent); err != nil {
+			return err
+		}
+	}
+	return c.prune()
+}
+
+// AddIndexer feeds the indexer all the blocks we have so far and every block we add or roll back from now on.
+func (c *Chain) AddIndexer(indexer Indexer) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.lowestHeight > 0 {
+		return fmt.Errorf("cannot index a chain without the blocks below height (%d)", c.lowestHeight)
+	}
+	for height := 0; height <= c.headers.Height(); height++ {
+		b, err := c.blockByHeight(height)
+		if err != nil {
+			return err
+		}
+		undo, ok := c.undo[height]
+		if !ok {
+			return fmt.Errorf("no undo data for block at height (%d)", height)
+		}
+		if err := indexer.AddBlock(b, height, undo.spent); err != nil {
+			return err
+		}
+	}
+	c.indexers = append(c.indexers, indexer)
+	return nil
+}
+
+// Notify feeds the indexer every block we add or roll back from now on, without the blocks we already have.
+func (c *Chain) Notify(indexer Indexer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.indexers = append(c.indexers, indexer)
+}
+
+// GetAnchor returns where the data with the given content hash (see types.HashData)
+// was first anchored on chain.
+func (c *Chain) GetAnchor(contentHash []byte) (*Anchor, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.anchorStore.Get(hex.EncodeToString(contentHash))
 }
 
 func (c *Chain) GetBlockByHash(hash []byte) (*proto.Block, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.blockByHash(hash)
+}
+
+func (c *Chain) blockByHash(hash []byte) (*proto.Block, error) {
 	hashHex := hex.EncodeToString(hash)
 	return c.blockStore.Get(hashHex)
 }
 
 func (c *Chain) GetBlockByHeight(height int) (*proto.Block, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.blockByHeight(height)
+}
+
+func (c *Chain) blockByHeight(height int) (*proto.Block, error) {
 	// We are going to check if we want to get a block by the height, it is going to check if we have it.
-	if c.Height() < height {
-		return nil, fmt.Errorf("given height (%d) too high - height (%d)", height, c.Height())
+	if c.headers.Height() < height {
+		return nil, fmt.Errorf("given height (%d) too high - height (%d)", height, c.headers.Height())
+	}
+	if height < c.lowestHeight {
+		return nil, fmt.Errorf("block at height (%d) has been pruned - lowest height (%d)", height, c.lowestHeight)
 	}
 	header := c.headers.Get(height)
 	hash := types.HashHeader(header)
-	return c.GetBlockByHash(hash)
+	return c.blockByHash(hash)
+}
+
+// GetTransaction returns a confirmed transaction and where it is on our chain.
+func (c *Chain) GetTransaction(hash []byte) (*proto.Transaction, *TxLocation, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.getTransaction(hash)
+}
+
+func (c *Chain) getTransaction(hash []byte) (*proto.Transaction, *TxLocation, error) {
+	hashHex := hex.EncodeToString(hash)
+	tx, err := c.txStore.Get(hashHex)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc, err := c.txStore.GetLocation(hashHex)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx, loc, nil
+}
+
+// Confirmations returns the number of blocks on top of (and including) the block at height.
+func (c *Chain) Confirmations(height int) int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.headers.Height() - height + 1
+}
+
+// GetTxProof returns a Merkle proof that the transaction is part of a block on our chain.
+// If we don't know the block hash we look it up in the tx index.
+func (c *Chain) GetTxProof(txHash []byte, blockHash []byte) (*proto.TxProof, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if len(blockHash) == 0 {
+		_, loc, err := c.getTransaction(txHash)
+		if err != nil {
+			return nil, err
+		}
+		blockHash, err = hex.DecodeString(loc.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	block, err := c.blockByHash(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	proof, err := types.GetMerkleProof(block, txHash)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.TxProof{
+		TxHash: txHash,
+		Header: block.Header,
+		Proof:  proof,
+	}, nil
 }
 
 func (c *Chain) ValidateBlock(b *proto.Block) error { // the b passed in the parameter is a new block.
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.validateBlock(b)
+}
+
+func (c *Chain) validateBlock(b *proto.Block) error {
 	// Validate the signature of the block.
 	if !types.VerifyBlock(b) {
 		return fmt.Errorf("invalide block signature")
 	}
+	if !c.genesis.IsValidator(b.PublicKey) {
+		return fmt.Errorf("block signer (%s) is not a validator", hex.EncodeToString(b.PublicKey))
+	}
+	if max := c.genesis.Consensus.MaxBlockTxs; max > 0 && len(b.Transactions) > max {
+		return fmt.Errorf("block has (%d) transactions, the max is (%d)", len(b.Transactions), max)
+	}
 
 	// validate if the previous hash is the actual hash of the current block.
-	currentBlock, err := c.GetBlockByHeight(c.Height()) // The hash of the new block b, will be the has of the current block.
+	currentBlock, err := c.blockByHeight(c.headers.Height()) // The hash of the new block b, will be the has of the current block.
 	if err != nil {
 		return err
 	}
@@ -155,82 +394,195 @@ func (c *Chain) ValidateBlock(b *proto.Block) error { // the b passed in the par
 		return fmt.Errorf("invalid previous block hash")
 	}
 
+	// Like BIP30, a tx is on the chain only once. Its hash is the key of its outputs, a second copy would
+	// overwrite them.
+	seen := map[string]bool{}
 	for _, tx := range b.Transactions {
-		if c.ValidateTransaction(tx); err != nil {
+		hash := hex.EncodeToString(types.HashTransaction(tx))
+		if seen[hash] {
+			return fmt.Errorf("tx %s is in the block twice", hash)
+		}
+		seen[hash] = true
+		if c.hasOutputsOf(hash, tx) {
+			return fmt.Errorf("tx %s is already on the chain", hash)
+		}
+		if err := c.validateTransaction(tx); err != nil {
 			return err
 		}
 	}
+
+	root, err := c.stateRootAfter(b)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root, b.Header.StateRoot) {
+		return fmt.Errorf("invalid state root")
+	}
 	return nil
 }
 
+// errUnknownInput is why a tx is invalid when it spends an output we don't have or that is spent already.
+// That depends on which blocks and txs have reached us, so it's not always the fault of whoever sent the tx.
+var errUnknownInput = errors.New("unknown or spent output")
+
+// hasOutputsOf reports whether the utxo set, spent outputs included, has an output of the tx.
+// We can't ask the txStore, pruning forgets the txs.
+func (c *Chain) hasOutputsOf(hash string, tx *proto.Transaction) bool {
+	for it, output := range tx.Outputs {
+		if types.IsDataOutput(output) {
+			continue
+		}
+		if _, err := c.utxoStore.Get(utxoKey(hash, it)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Chain) ValidateTransaction(tx *proto.Transaction) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.validateTransaction(tx)
+}
+
+func (c *Chain) validateTransaction(tx *proto.Transaction) error {
 	// Verify the signature
-	if !types.VerifyTransaction(tx) {
-		return fmt.Errorf("invalid tx signature")
+	if err := types.VerifyTransaction(tx); err != nil {
+		return fmt.Errorf("invalid tx signature: %w", err)
 	}
 	// Check if all the inputs are unspent.
 	var (
-		nInputs = len(tx.Inputs)
-		hash     = hex.EncodeToString(types.HashTransaction(tx))
+		hash      = hex.EncodeToString(types.HashTransaction(tx))
+		sumInputs = map[string]uint64{} // We keep a sum for each asset, you cannot pay loyalty points with native coins.
+		seen      = map[string]bool{}
 	)
-	sumInputs := 0
-	for i := 0; i < nInputs; i++ {
-		prevHash := hex.EncodeToString(tx.Inputs[i].PrevTxHash)
-		key := fmt.Sprintf("%s_%d", prevHash, i)
-
-		// fmt.Println("phash =>", prevHash)
+	for i, input := range tx.Inputs {
+		key := fmt.Sprintf("%s_%d", hex.EncodeToString(input.PrevTxHash), input.PrevOutIndex)
+		if seen[key] {
+			return fmt.Errorf("input %d of tx %s spends the same output twice", i, hash)
+		}
+		seen[key] = true
 
 		utxo, err := c.utxoStore.Get(key)
-		sumInputs += int(utxo.Amount)
 		if err != nil {
-			return err
+			return fmt.Errorf("input %d of tx %s spends an %w: %s", i, hash, errUnknownInput, err)
 		}
 		if utxo.Spent {
-			return fmt.Errorf("input %d of tx %s is already spent", i, hash)
+			return fmt.Errorf("input %d of tx %s spends an %w", i, hash, errUnknownInput)
+		}
+		// A valid signature only counts when it's of the key that owns the output.
+		pubKey, err := crypto.PublicKeyFromBytes(input.PublicKey)
+		if err != nil {
+			return err
 		}
+		if pubKey.Address().String() != utxo.Address {
+			return fmt.Errorf("input %d of tx %s is not signed by the owner of the output", i, hash)
+		}
+		if !addAmount(sumInputs, utxo.Asset, utxo.Amount) {
+			return fmt.Errorf("inputs of asset (%s) overflow in tx %s", utxo.Asset, hash)
+		}
+	}
+
+	issued := hex.EncodeToString(types.IssuedAssetID(tx))
+	if tx.Issuance != nil && len(issued) == 0 {
+		return fmt.Errorf("invalid issuance in tx %s", hash)
 	}
-	sumOutputs := 0
+	// The issuer spends one of its own outputs in the issuance, that makes a signed issuance one-shot.
+	// Otherwise anybody could put it into a block again and mint the asset once more.
+	if tx.Issuance != nil && !hasInputOf(tx, tx.Issuance.PublicKey) {
+		return fmt.Errorf("issuance in tx %s has no input of the issuer", hash)
+	}
+	sumOutputs := map[string]uint64{}
 	for _, output := range tx.Outputs {
-		sumOutputs+= int(output.Amount)
+		if types.IsDataOutput(output) {
+			if len(output.Data) > types.MaxDataLen {
+				return fmt.Errorf("data output too large (%d) max (%d) in tx %s", len(output.Data), types.MaxDataLen, hash)
+			}
+			if output.Amount > 0 {
+				return fmt.Errorf("data output cannot carry an amount in tx %s", hash)
+			}
+			continue
+		}
+		asset := hex.EncodeToString(output.Asset)
+		if asset != NativeAsset && len(output.Asset) != types.AssetIDLen {
+			return fmt.Errorf("invalid asset id length (%d) in tx %s", len(output.Asset), hash)
+		}
+		if !addAmount(sumOutputs, asset, output.Amount) {
+			return fmt.Errorf("outputs of asset (%s) overflow in tx %s", asset, hash)
+		}
 	}
 
-	if sumInputs < sumOutputs {
-		return fmt.Errorf("insufficient balance got (%d) spending (%d)", sumInputs, sumOutputs)
+	for asset, spending := range sumOutputs {
+		if tx.Issuance != nil && asset == issued {
+			continue // The issuer mints these out of thin air.
+		}
+		if sumInputs[asset] < spending {
+			return fmt.Errorf("insufficient balance for asset (%s) got (%d) spending (%d)", asset, sumInputs[asset], spending)
+		}
 	}
 
 	return nil
 }
 
-func createGenesisBlock() *proto.Block {
-	// godSeed is global. We have a godSeed we can always use.
-	privKey := crypto.NewPrivateKeyFromSeedStr(godSeed) // Now we will always have a deterministic privateKey.
-
-	block := &proto.Block{
-		Header: &proto.Header{
-			Version: 1,
-		},
+func hasInputOf(tx *proto.Transaction, pubKey []byte) bool {
+	for _, input := range tx.Inputs {
+		if bytes.Equal(input.PublicKey, pubKey) {
+			return true
+		}
 	}
+	return false
+}
 
-	tx := &proto.Transaction{
-		Version: 1,
-		Inputs:  []*proto.TxInput{}, // We don't need the input only the outputs.Because we are not going to validate this. Because it is the genesis (first) block, we don't give a shit. We are going to create tokens out of thin air.
-		Outputs: []*proto.TxOutput{ // We are gong to output the seed above so we can use it in our test. This is going to be index / output 0.
-			{
-				Amount:  1000,
-				Address: privKey.Public().Address().Bytes(),
-			},
-		},
+// addAmount adds amount to the sum of asset. It reports false when the sum overflows, it would wrap around
+// and let a tx spend more than it has.
+func addAmount(sums map[string]uint64, asset string, amount uint64) bool {
+	sum, carry := bits.Add64(sums[asset], amount, 0)
+	sums[asset] = sum
+	return carry == 0
+}
+
+// GetBalance returns the unspent amount of each asset owned by the address.
+// The native coin is stored under NativeAsset.
+func (c *Chain) GetBalance(address []byte) (map[string]uint64, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	utxos, err := c.utxosOf(address)
+	if err != nil {
+		return nil, err
 	}
+	balance := map[string]uint64{}
+	for _, utxo := range utxos {
+		balance[utxo.Asset] += utxo.Amount
+	}
+	return balance, nil
+}
 
-	block.Transactions = append(block.Transactions, tx)
-	types.SignBlock(privKey, block)
-	// We also need a Merkle tree, a merkle root of all the transactions inside of it and put it in a block so we can sign that so we have a deterministic way. The merkle root of the tx we need to hash. We don't hash transactions we need to hash the root. Otherwise we can tamper with these transaction which is a security hazard.
-	return block
+// GetUTXOs returns the unspent outputs owned by the address, sorted by tx hash and output index.
+func (c *Chain) GetUTXOs(address []byte) ([]*UTXO, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.utxosOf(address)
 }
 
-// The Genesis Block right now will not be deterministic at all times because each time we are going to createGenesisBlock it is going to create a new privatekey
-// and it should be one from a seed. A we are going to create the Genesis Block with NewChain(). The problem is that we cannot validate the genisis block like any other block
-// because it is the genesis block. We don't have a previous hash due to the genesis block being the first block.
+func (c *Chain) utxosOf(address []byte) ([]*UTXO, error) {
+	utxos, err := c.utxoStore.List()
+	if err != nil {
+		return nil, err
+	}
+	addr := hex.EncodeToString(address)
+	owned := []*UTXO{}
+	for _, utxo := range utxos {
+		if utxo.Spent || utxo.Address != addr {
+			continue
+		}
+		owned = append(owned, utxo)
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		return utxoKey(owned[i].Hash, owned[i].OutIndex) < utxoKey(owned[j].Hash, owned[j].OutIndex)
+	})
+	return owned, nil
+}
 
 // In order to check if the amount has been spent or not spent, we are going to keep track of the unspent transaction outputs.
 
@@ -1,6 +1,7 @@
 package node
 
 import (
+	"encoding/hex"
 	"fmt"
 	"testing"
 
@@ -18,13 +19,60 @@ func randomBlock(t *testing.T, chain *Chain) *proto.Block {
 	prevBlock, err := chain.GetBlockByHeight(chain.Height())
 	require.Nil(t, err)
 	b.Header.PrevHash = types.HashBlock(prevBlock)
-	types.SignBlock(privKey, b)
+	signBlock(t, chain, privKey, b)
 	return b
 }
 
+// signBlock sets the state root the chain will have after the block and signs it.
+// Call it again each time you add transactions to the block.
+func signBlock(t *testing.T, chain *Chain, privKey *crypto.PrivateKey, b *proto.Block) {
+	root, err := chain.StateRootAfter(b)
+	require.Nil(t, err)
+	b.Header.StateRoot = root
+	types.SignBlock(privKey, b)
+}
+
+// genesisTx returns the transaction of the genesis block that pays 1000 coins to the devSeed address.
+func genesisTx(chain *Chain) (*proto.Transaction, error) {
+	genesis, err := chain.GetBlockByHeight(0)
+	if err != nil {
+		return nil, err
+	}
+	return chain.txStore.Get(hex.EncodeToString(types.HashTransaction(genesis.Transactions[0])))
+}
+
+// spendGenesisTx returns a signed tx sending amount of the genesis coins to recipient and the rest back to the devSeed address.
+func spendGenesisTx(t *testing.T, chain *Chain, recipient []byte, amount uint64) *proto.Transaction {
+	privKey := devKey()
+	prevTx, err := genesisTx(chain)
+	require.Nil(t, err)
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(prevTx),
+				PrevOutIndex: 0,
+				PublicKey:    privKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  amount,
+				Address: recipient,
+			},
+			{
+				Amount:  1000 - amount,
+				Address: privKey.Public().Address().Bytes(),
+			},
+		},
+	}
+	tx.Inputs[0].Signature = types.SignTransaction(privKey, tx).Bytes()
+	return tx
+}
+
 // Check if the Genesis Block was created.
 func TestNewChain(t *testing.T) {
-	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
 	assert.Equal(t, 0, chain.Height())
 	/*block*/ _, err := chain.GetBlockByHeight(0) // block is the genesis block. We don't care about the block, the only thing we want is that the block exists (has been created in the chain).
 
@@ -32,7 +80,7 @@ func TestNewChain(t *testing.T) {
 }
 
 func TestChainHeight(t *testing.T) {
-	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
 	for i := 0; i < 100; i++ {
 		b := randomBlock(t, chain)
 		// b := util.RandomBlock() // These commented lines are replaced by the helper function randomBlock
@@ -46,7 +94,7 @@ func TestChainHeight(t *testing.T) {
 }
 
 func TestAddBlock(t *testing.T) {
-	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
 
 	for i := 0; i < 100; i++ {
 
@@ -71,12 +119,12 @@ func TestAddBlock(t *testing.T) {
 
 func TestAddBlockWithInsufficientFunds(t *testing.T) {
 	var (
-		chain = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		chain = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
 		block = randomBlock(t, chain)
-		privKey = crypto.NewPrivateKeyFromSeedStr(godSeed)
+		privKey = devKey()
 		recipient = crypto.GeneratePrivateKey().Public().Address().Bytes()
 	)
-	prevTx, err := chain.txStore.Get("hashPrintedFromFilechain.go-addBlockfuncfmtPrintlnTX:") // - fetch transaction transaction. Going to fetch a transaction that we stored because in that transaction there are my outputs. The outputs that I need to use for inputs below. This is nasty code.
+	prevTx, err := genesisTx(chain) // - fetch transaction transaction. Going to fetch a transaction that we stored because in that transaction there are my outputs. The outputs that I need to use for inputs below.
 	assert.Nil(t, err)
 	inputs := []*proto.TxInput{
 		{
@@ -102,17 +150,42 @@ func TestAddBlockWithInsufficientFunds(t *testing.T) {
 	require.NotNil(t, chain.AddBlock(block)) // Adding a block should fail due to not having enough funds.
 }
 
+func TestValidateTransactionOverflow(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	// The outputs 2^63 and 1000 - 2^63 add up to the 1000 of the input once the sum wraps around.
+	tx := spendGenesisTx(t, chain, crypto.GeneratePrivateKey().Public().Address().Bytes(), 1<<63)
+	assert.NotNil(t, chain.ValidateTransaction(tx))
+}
+
+func TestValidateTransactionNotSignedByOwner(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	tx := spendGenesisTx(t, chain, crypto.GeneratePrivateKey().Public().Address().Bytes(), 10)
+	thief := crypto.GeneratePrivateKey()
+	tx.Inputs[0].PublicKey = thief.Public().Bytes()
+	tx.Inputs[0].Signature = types.SignTransaction(thief, tx).Bytes()
+	assert.NotNil(t, chain.ValidateTransaction(tx))
+}
+
+func TestValidateTransactionMalformedSignature(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	tx := spendGenesisTx(t, chain, crypto.GeneratePrivateKey().Public().Address().Bytes(), 10)
+	tx.Inputs[0].Signature = tx.Inputs[0].Signature[:10]
+	assert.NotNil(t, chain.ValidateTransaction(tx))
+	tx.Inputs[0].PublicKey = []byte{1, 2, 3}
+	assert.NotNil(t, chain.ValidateTransaction(tx))
+}
+
 func TestAddblockWithTx(t *testing.T) {
 	var (
-		chain = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		chain = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
 		block = randomBlock(t, chain)
-		privKey = crypto.NewPrivateKeyFromSeedStr(godSeed)
+		privKey = devKey()
 		recipient = crypto.GeneratePrivateKey().Public().Address().Bytes()
 	)
 	// The input of a transaction is the output of a previous transaction. 
 	// In the input we need to specify the previous output.
 
-	prevTx, err := chain.txStore.Get("hashPrintedFromFilechain.go-addBlockfuncfmtPrintlnTX:") // - fetch transaction transaction. Going to fetch a transaction that we stored because in that transaction there are my outputs. The outputs that I need to use for inputs below. This is nasty code.
+	prevTx, err := genesisTx(chain) // - fetch transaction transaction. Going to fetch a transaction that we stored because in that transaction there are my outputs. The outputs that I need to use for inputs below.
 	assert.Nil(t, err) // prevTx is the previous transaction. And this tx actually be the Genesis block in createGenesisBlock() in chain.go. With the output with amount 1000. It is currently false for Spent but we are going to set Spent to true as in we spent the tokens.
 
 
@@ -145,8 +218,340 @@ func TestAddblockWithTx(t *testing.T) {
 	tx.Inputs[0].Signature = sig.Bytes()
 
 	block.Transactions = append(block.Transactions, tx)
-	types.SignBlock(privKey, block)
+	signBlock(t, chain, privKey, block)
+	require.Nil(t, chain.AddBlock(block))
+}
+
+// issuanceTx returns a tx of the devSeed key minting amount of its asset called name for recipient.
+// It pays for it with the genesis output, the coins go back to the devSeed address.
+func issuanceTx(t *testing.T, chain *Chain, recipient []byte, name string, amount uint64) *proto.Transaction {
+	issuer := devKey()
+	tx := spendGenesisTx(t, chain, recipient, 0)
+	tx.Outputs[0].Amount = amount
+	tx.Outputs[0].Asset = types.AssetID(issuer.Public(), name)
+	tx.Issuance = &proto.AssetIssuance{
+		Name:      name,
+		PublicKey: issuer.Public().Bytes(),
+	}
+	tx.Inputs[0].Signature = types.SignTransaction(issuer, tx).Bytes()
+	types.SignIssuance(issuer, tx)
+	return tx
+}
+
+func TestAddBlockWithIssuance(t *testing.T) {
+	var (
+		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+		block     = randomBlock(t, chain)
+		recipient = crypto.GeneratePrivateKey().Public().Address().Bytes()
+		assetID   = types.AssetID(devKey().Public(), "POINTS")
+	)
+	tx := issuanceTx(t, chain, recipient, "POINTS", 500)
+	block.Transactions = append(block.Transactions, tx)
+	signBlock(t, chain, devKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	balance, err := chain.GetBalance(recipient)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]uint64{hex.EncodeToString(assetID): 500}, balance)
+}
+
+func TestIssuanceNeedsAnInputOfTheIssuer(t *testing.T) {
+	var (
+		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+		issuer    = crypto.GeneratePrivateKey()
+		recipient = crypto.GeneratePrivateKey().Public().Address().Bytes()
+	)
+	tx := &proto.Transaction{
+		Version: 1,
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  500,
+				Address: recipient,
+				Asset:   types.AssetID(issuer.Public(), "POINTS"),
+			},
+		},
+		Issuance: &proto.AssetIssuance{
+			Name: "POINTS",
+		},
+	}
+	types.SignIssuance(issuer, tx)
+	require.NotNil(t, chain.ValidateTransaction(tx))
+
+	// The input of somebody else doesn't do either.
+	tx = issuanceTx(t, chain, recipient, "POINTS", 500)
+	tx.Outputs[0].Asset = types.AssetID(issuer.Public(), "POINTS")
+	types.SignIssuance(issuer, tx)
+	require.NotNil(t, chain.ValidateTransaction(tx))
+}
+
+func TestIssuanceCannotBeReplayed(t *testing.T) {
+	var (
+		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+		holder    = crypto.GeneratePrivateKey()
+		recipient = crypto.GeneratePrivateKey().Public().Address().Bytes()
+		issuance  = issuanceTx(t, chain, holder.Public().Address().Bytes(), "POINTS", 500)
+	)
+	// The same tx twice in one block.
+	block := randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, issuance, issuance)
+	types.SignBlock(devKey(), block)
+	require.NotNil(t, chain.AddBlock(block))
+
+	block = randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, issuance)
+	signBlock(t, chain, devKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	// The holder spends the points.
+	spend := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(issuance),
+				PrevOutIndex: 0,
+				PublicKey:    holder.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  500,
+				Address: recipient,
+				Asset:   issuance.Outputs[0].Asset,
+			},
+		},
+	}
+	spend.Inputs[0].Signature = types.SignTransaction(holder, spend).Bytes()
+	block = randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, spend)
+	signBlock(t, chain, devKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	// Putting the issuance into a block again doesn't mint the points again, even once the chain forgot the txs.
+	require.Nil(t, chain.EnablePruning(1))
+	block = randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, issuance)
+	_, err := chain.StateRootAfter(block)
+	require.NotNil(t, err)
+	types.SignBlock(devKey(), block)
+	require.NotNil(t, chain.AddBlock(block))
+
+	balance, err := chain.GetBalance(holder.Public().Address().Bytes())
+	require.Nil(t, err)
+	assert.Empty(t, balance)
+	utxos, err := chain.utxoStore.List()
+	require.Nil(t, err)
+	root, err := chain.StateRoot()
+	require.Nil(t, err)
+	assert.Equal(t, CalculateStateRoot(utxos), root)
+}
+
+func TestTxIsOnTheChainOnlyOnce(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	// Nothing to spend, so only its hash tells the copies apart.
+	tx := &proto.Transaction{
+		Version: 1,
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  0,
+				Address: crypto.GeneratePrivateKey().Public().Address().Bytes(),
+			},
+		},
+	}
+	require.Nil(t, chain.ValidateTransaction(tx))
+
+	block := randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, tx, tx)
+	_, err := chain.StateRootAfter(block)
+	require.NotNil(t, err)
+	types.SignBlock(devKey(), block)
+	require.NotNil(t, chain.AddBlock(block))
+
+	block = randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, tx)
+	signBlock(t, chain, devKey(), block)
 	require.Nil(t, chain.AddBlock(block))
+
+	block = randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, tx)
+	_, err = chain.StateRootAfter(block)
+	require.NotNil(t, err)
+	types.SignBlock(devKey(), block)
+	require.NotNil(t, chain.AddBlock(block))
+
+	utxos, err := chain.utxoStore.List()
+	require.Nil(t, err)
+	root, err := chain.StateRoot()
+	require.Nil(t, err)
+	assert.Equal(t, CalculateStateRoot(utxos), root)
+}
+
+func TestIssuanceCannotMintOtherAssets(t *testing.T) {
+	var (
+		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+		recipient = crypto.GeneratePrivateKey().Public().Address().Bytes()
+	)
+	tx := issuanceTx(t, chain, recipient, "POINTS", 500)
+	tx.Outputs[0].Asset = types.AssetID(crypto.GeneratePrivateKey().Public(), "POINTS") // Someone else's asset.
+	tx.Inputs[0].Signature = types.SignTransaction(devKey(), tx).Bytes()
+	types.SignIssuance(devKey(), tx)
+	require.NotNil(t, chain.ValidateTransaction(tx))
+
+	tx = issuanceTx(t, chain, recipient, "POINTS", 500)
+	tx.Outputs[1].Amount++ // Native coins are never minted by an issuance.
+	tx.Inputs[0].Signature = types.SignTransaction(devKey(), tx).Bytes()
+	types.SignIssuance(devKey(), tx)
+	require.NotNil(t, chain.ValidateTransaction(tx))
+}
+
+func TestTransferAssetInsufficientFunds(t *testing.T) {
+	var (
+		chain   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+		privKey = devKey()
+		assetID = types.AssetID(crypto.GeneratePrivateKey().Public(), "USD")
+	)
+	prevTx, err := genesisTx(chain)
+	require.Nil(t, err)
+
+	// The genesis output is 1000 native coins, we cannot spend them as another asset.
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(prevTx),
+				PrevOutIndex: 0,
+				PublicKey:    privKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  1,
+				Address: privKey.Public().Address().Bytes(),
+				Asset:   assetID,
+			},
+		},
+	}
+	tx.Inputs[0].Signature = types.SignTransaction(privKey, tx).Bytes()
+	require.NotNil(t, chain.ValidateTransaction(tx))
+
+	tx.Outputs[0].Asset = nil
+	tx.Inputs[0].Signature = types.SignTransaction(privKey, tx).Bytes()
+	require.Nil(t, chain.ValidateTransaction(tx))
+
+	balance, err := chain.GetBalance(privKey.Public().Address().Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, uint64(1000), balance[NativeAsset])
+}
+
+func TestAddBlockWithDataOutput(t *testing.T) {
+	var (
+		chain   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+		block   = randomBlock(t, chain)
+		privKey = devKey()
+		docHash = util.RandomHash() // The hash of the document we want to timestamp.
+	)
+	prevTx, err := genesisTx(chain)
+	require.Nil(t, err)
+
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(prevTx),
+				PrevOutIndex: 0,
+				PublicKey:    privKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Data: docHash,
+			},
+			{
+				Amount:  1000,
+				Address: privKey.Public().Address().Bytes(),
+			},
+		},
+	}
+	tx.Inputs[0].Signature = types.SignTransaction(privKey, tx).Bytes()
+	block.Transactions = append(block.Transactions, tx)
+	signBlock(t, chain, privKey, block)
+	require.Nil(t, chain.AddBlock(block))
+
+	anchor, err := chain.GetAnchor(types.HashData(docHash))
+	require.Nil(t, err)
+	assert.Equal(t, 1, anchor.Height)
+	assert.Equal(t, hex.EncodeToString(types.HashBlock(block)), anchor.BlockHash)
+	assert.Equal(t, hex.EncodeToString(types.HashTransaction(tx)), anchor.TxHash)
+	assert.Equal(t, 0, anchor.OutIndex)
+
+	// The data output never made it into the utxo set, so nobody can spend it.
+	_, err = chain.utxoStore.Get(fmt.Sprintf("%s_%d", anchor.TxHash, 0))
+	assert.NotNil(t, err)
+}
+
+func TestDataOutputTooLarge(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	tx := &proto.Transaction{
+		Version: 1,
+		Outputs: []*proto.TxOutput{
+			{
+				Data: make([]byte, types.MaxDataLen+1),
+			},
+		},
+	}
+	require.NotNil(t, chain.ValidateTransaction(tx))
+
+	tx.Outputs[0].Data = make([]byte, types.MaxDataLen)
+	require.Nil(t, chain.ValidateTransaction(tx))
+}
+
+func TestGetTxProof(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	genesis, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		require.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	}
+
+	txHash := types.HashTransaction(genesis.Transactions[0])
+	proof, err := chain.GetTxProof(txHash, nil)
+	require.Nil(t, err)
+	assert.True(t, types.VerifyTxProof(proof))
+	assert.Equal(t, types.HashBlock(genesis), types.HashHeader(proof.Header))
+
+	proof, err = chain.GetTxProof(txHash, types.HashBlock(genesis))
+	require.Nil(t, err)
+	assert.True(t, types.VerifyTxProof(proof))
+
+	_, err = chain.GetTxProof(util.RandomHash(), nil)
+	assert.NotNil(t, err)
+}
+
+func TestGetTransaction(t *testing.T) {
+	var (
+		chain   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+		block   = randomBlock(t, chain)
+		privKey = crypto.GeneratePrivateKey()
+		tx      = spendGenesisTx(t, chain, privKey.Public().Address().Bytes(), 10)
+	)
+	block.Transactions = append(block.Transactions, &proto.Transaction{Version: 1}, tx)
+	signBlock(t, chain, privKey, block)
+	require.Nil(t, chain.AddBlock(block))
+
+	fetched, loc, err := chain.GetTransaction(types.HashTransaction(tx))
+	require.Nil(t, err)
+	assert.Equal(t, tx, fetched)
+	assert.Equal(t, hex.EncodeToString(types.HashBlock(block)), loc.BlockHash)
+	assert.Equal(t, 1, loc.Height)
+	assert.Equal(t, 1, loc.Index)
+	assert.Equal(t, 1, chain.Confirmations(loc.Height))
+
+	for i := 0; i < 5; i++ {
+		require.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	}
+	assert.Equal(t, 6, chain.Confirmations(loc.Height))
+
+	_, _, err = chain.GetTransaction(util.RandomHash())
+	assert.NotNil(t, err)
 }
 
 // So what are we doing? We create a random block, we store it into a chain, we fetch it back and then we compare if the the thing we stored the block
@@ -156,7 +561,7 @@ func TestAddblockWithTx(t *testing.T) {
 // If you make a transaction, if I want to send 50 bitcons to somebody, I need to query the blockchain (database), and specify unspent outputs and and use them as inputs
 // in my new transaction. In this case it is a test and we cannot specify any outputs in our inputs because we don't have outputs. 
 
-// In our Genesis Block we are sending money from nowhere (created out of thin air) to the godSeed. Then we are going to send from the our godSeed address to the recipient varaible in the function above. 
+// In our Genesis Block we are sending money from nowhere (created out of thin air) to the devSeed. Then we are going to send from the our devSeed address to the recipient varaible in the function above. 
 // We are sending 100 in the outputs := []*proto.TxOutput but we have 1000 so we need to specify in our inputs the output from the Genesis with is 0 (PrevOutIndex: 0). 
 // In the outputs, we make another transaction back to ourselves for the 900 left out of the 1000 (we sent 100)
 
@@ -167,7 +572,7 @@ func TestAddblockWithTx(t *testing.T) {
 // But I need to have an input or multiple inputs with the sum of atleast 100 tokens.
 // In order to test this, we create a Genesis block. The GenesisBlock is the first block of a block chain. Im going to create some coins out of thin air.
 // And Im going to send them the 1000 coins to the private key in chain.go createGenesisBlock().
-// And the private key is going to be the godSeed variable. So it is going to be the address of the godSeed.
+// And the private key is going to be the devSeed variable. So it is going to be the address of the devSeed.
 // And that is going to be prevTx varaible in TestAddBlockWithTx() above.
 // So what we do is check the hash of the transaction. We got the hash of the transaction in prevTx and we are going to fetch that transaction 
 // so we can use the output as an input for our test. This is important to understand and it is what makes it secure.
@@ -175,3 +580,36 @@ func TestAddblockWithTx(t *testing.T) {
 
 // BITCOIN USES UTXO.
 
+
+func TestChainConcurrentReadsAndWrites(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	address := devKey().Public().Address().Bytes()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			if err := chain.AddBlock(randomBlock(t, chain)); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		if err := chain.RollbackBlock(); err != nil {
+			t.Error(err)
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			assert.Equal(t, 19, chain.Height())
+			return
+		default:
+		}
+		_, err := chain.GetBlockByHeight(chain.LowestHeight())
+		require.Nil(t, err)
+		balance, err := chain.GetBalance(address)
+		require.Nil(t, err)
+		assert.Equal(t, uint64(1000), balance[NativeAsset])
+		_, err = chain.StateRoot()
+		require.Nil(t, err)
+	}
+}
@@ -0,0 +1,165 @@
+package node
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Fito305/blocker/crypto"
+	"go.uber.org/zap"
+)
+
+// Config is the JSON config file of a node, `blocker node start --config <file>`.
+// Relative paths in it are relative to the directory of the config file.
+type Config struct {
+	Version          string   `json:"version"`
+	GenesisFile      string   `json:"genesisFile"` // The dev network when empty, see DefaultGenesis.
+	DataDir          string   `json:"dataDir"`
+	LogLevel         string   `json:"logLevel"` // debug, info, warn or error.
+	ListenAddr       string   `json:"listenAddr"`
+	AdvertiseAddr    string   `json:"advertiseAddr"` // host:port, when peers can't dial us at listenAddr.
+	PublicListenAddr string   `json:"publicListenAddr"`
+	APIListenAddr    string   `json:"apiListenAddr"`
+	AdminListenAddr  string   `json:"adminListenAddr"`
+	BootstrapNodes   []string `json:"bootstrapNodes"`
+	ValidatorKeyFile string   `json:"validatorKeyFile"` // Only validators have one, see crypto.ReadPrivateKeyFile.
+	NodeKeyFile      string   `json:"nodeKeyFile"`      // Created when missing. node.key in the dataDir when empty.
+	MaxInboundPeers  int      `json:"maxInboundPeers"`  // 0 means the default.
+	MaxOutboundPeers int      `json:"maxOutboundPeers"` // 0 means the default.
+
+	PeerToken       string    `json:"peerToken"`
+	PeerRateLimit   RateLimit `json:"peerRateLimit"`
+	PublicToken     string    `json:"publicToken"`
+	PublicRateLimit RateLimit `json:"publicRateLimit"`
+	AdminToken      string    `json:"adminToken"`
+
+	PeerTLS   *TLSConfig `json:"peerTLS"` // Plaintext when missing, see TLSConfig.
+	PublicTLS *TLSConfig `json:"publicTLS"`
+	AdminTLS  *TLSConfig `json:"adminTLS"`
+	APITLS    *TLSConfig `json:"apiTLS"`
+
+	APIOrigins []string `json:"apiOrigins"` // The websites allowed on the websocket of the JSON API, "*" is any.
+
+	BanThreshold int `json:"banThreshold"` // 0 means the default.
+	BanDuration  int `json:"banDuration"`  // seconds, 0 means the default.
+
+	SnapshotFile     string `json:"snapshotFile"`
+	TrustedBlockHash string `json:"trustedBlockHash"` // hex
+	PruneBlocks      int    `json:"pruneBlocks"`
+	IndexAddresses   bool   `json:"indexAddresses"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if cfg.ListenAddr == "" {
+		return nil, fmt.Errorf("invalid config file %s: listenAddr is required", path)
+	}
+	if cfg.AdvertiseAddr != "" {
+		addr, err := normalizeAddr(cfg.AdvertiseAddr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		if host, _, _ := net.SplitHostPort(addr); !hasHost(host) {
+			return nil, fmt.Errorf("invalid config file %s: advertiseAddr %q has no host", path, cfg.AdvertiseAddr)
+		}
+		cfg.AdvertiseAddr = addr
+	}
+	if cfg.LogLevel != "" {
+		if _, err := zap.ParseAtomicLevel(cfg.LogLevel); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+	// Headers aren't signed, anybody can make up a chain whose utxo set matches its own state root.
+	// Only the hash of the tip from somebody we trust makes a snapshot worth anything.
+	if cfg.SnapshotFile != "" && cfg.TrustedBlockHash == "" {
+		return nil, fmt.Errorf("invalid config file %s: snapshotFile needs a trustedBlockHash", path)
+	}
+	dir := filepath.Dir(path)
+	paths := []*string{&cfg.GenesisFile, &cfg.DataDir, &cfg.ValidatorKeyFile, &cfg.NodeKeyFile, &cfg.SnapshotFile}
+	for _, tls := range []*TLSConfig{cfg.PeerTLS, cfg.PublicTLS, cfg.AdminTLS, cfg.APITLS} {
+		if tls != nil {
+			paths = append(paths, &tls.CertFile, &tls.KeyFile, &tls.CAFile)
+		}
+	}
+	for _, p := range paths {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	return cfg, nil
+}
+
+// ServerConfig reads the genesis, the node key and the validator key, if there is one, and returns the config NewNode wants.
+func (cfg *Config) ServerConfig() (ServerConfig, error) {
+	server := ServerConfig{
+		Version:          cfg.Version,
+		DataDir:          cfg.DataDir,
+		LogLevel:         cfg.LogLevel,
+		ListenAddr:       cfg.ListenAddr,
+		AdvertiseAddr:    cfg.AdvertiseAddr,
+		PublicListenAddr: cfg.PublicListenAddr,
+		APIListenAddr:    cfg.APIListenAddr,
+		AdminListenAddr:  cfg.AdminListenAddr,
+		MaxInboundPeers:  cfg.MaxInboundPeers,
+		MaxOutboundPeers: cfg.MaxOutboundPeers,
+		PeerToken:        cfg.PeerToken,
+		PeerRateLimit:    cfg.PeerRateLimit,
+		PublicToken:      cfg.PublicToken,
+		PublicRateLimit:  cfg.PublicRateLimit,
+		AdminToken:       cfg.AdminToken,
+		PeerTLS:          cfg.PeerTLS,
+		PublicTLS:        cfg.PublicTLS,
+		AdminTLS:         cfg.AdminTLS,
+		APITLS:           cfg.APITLS,
+		APIOrigins:       cfg.APIOrigins,
+		BanThreshold:     cfg.BanThreshold,
+		BanDuration:      time.Duration(cfg.BanDuration) * time.Second,
+		SnapshotFile:     cfg.SnapshotFile,
+		PruneBlocks:      cfg.PruneBlocks,
+		IndexAddresses:   cfg.IndexAddresses,
+	}
+	if cfg.GenesisFile != "" {
+		genesis, err := LoadGenesis(cfg.GenesisFile)
+		if err != nil {
+			return server, err
+		}
+		server.Genesis = genesis
+	}
+	if cfg.ValidatorKeyFile != "" {
+		privKey, err := crypto.ReadPrivateKeyFile(cfg.ValidatorKeyFile)
+		if err != nil {
+			return server, err
+		}
+		server.PrivateKey = privKey
+	}
+	keyFile := cfg.NodeKeyFile
+	if keyFile == "" && cfg.DataDir != "" {
+		keyFile = filepath.Join(cfg.DataDir, nodeKeyFileName)
+	}
+	if keyFile != "" {
+		nodeKey, err := LoadOrCreateNodeKey(keyFile)
+		if err != nil {
+			return server, err
+		}
+		server.NodeKey = nodeKey
+	}
+	if cfg.TrustedBlockHash != "" {
+		hash, err := hex.DecodeString(cfg.TrustedBlockHash)
+		if err != nil {
+			return server, fmt.Errorf("invalid trustedBlockHash: %w", err)
+		}
+		server.TrustedBlockHash = hash
+	}
+	return server, nil
+}
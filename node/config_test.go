@@ -0,0 +1,78 @@
+package node
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		keyFile = filepath.Join(dir, "validator.key")
+		cfgFile = filepath.Join(dir, "node.json")
+		privKey = crypto.GeneratePrivateKey()
+	)
+	require.Nil(t, crypto.WritePrivateKeyFile(keyFile, privKey))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "genesis.json"), []byte(`{
+		"chainId": "blocker-test",
+		"allocations": [{"address": "`+privKey.Public().Address().String()+`", "amount": 100}],
+		"consensus": {"blockTime": 1000}
+	}`), 0600))
+	require.Nil(t, os.WriteFile(cfgFile, []byte(`{
+		"genesisFile": "genesis.json",
+		"dataDir": "data",
+		"logLevel": "info",
+		"listenAddr": ":3000",
+		"advertiseAddr": "Node-1.Example:3000",
+		"publicListenAddr": ":3001",
+		"bootstrapNodes": [":4000", ":5000"],
+		"validatorKeyFile": "`+keyFile+`",
+		"publicRateLimit": {"perSecond": 10, "burst": 20},
+		"peerTLS": {"caFile": "ca.pem"},
+		"trustedBlockHash": "abcd",
+		"pruneBlocks": 100
+	}`), 0600))
+
+	cfg, err := LoadConfig(cfgFile)
+	require.Nil(t, err)
+	assert.Equal(t, []string{":4000", ":5000"}, cfg.BootstrapNodes)
+
+	server, err := cfg.ServerConfig()
+	require.Nil(t, err)
+	assert.Equal(t, ":3000", server.ListenAddr)
+	assert.Equal(t, "node-1.example:3000", server.AdvertiseAddr)
+	assert.Equal(t, ":3001", server.PublicListenAddr)
+	assert.Equal(t, RateLimit{PerSecond: 10, Burst: 20}, server.PublicRateLimit)
+	assert.Equal(t, []byte{0xab, 0xcd}, server.TrustedBlockHash)
+	assert.Equal(t, 100, server.PruneBlocks)
+	assert.Equal(t, privKey.Bytes(), server.PrivateKey.Bytes())
+	assert.Equal(t, "blocker-test", server.Genesis.ChainID)
+	assert.Equal(t, filepath.Join(dir, "data"), server.DataDir) // Relative to the config file.
+	assert.Equal(t, "info", server.LogLevel)
+	assert.Equal(t, &TLSConfig{CAFile: filepath.Join(dir, "ca.pem")}, server.PeerTLS)
+	assert.Nil(t, server.PublicTLS)
+
+	// The node key is created in the data dir the first time, after that we keep being the same node.
+	require.NotNil(t, server.NodeKey)
+	assert.FileExists(t, filepath.Join(dir, "data", nodeKeyFileName))
+	again, err := cfg.ServerConfig()
+	require.Nil(t, err)
+	assert.Equal(t, server.NodeKey.Bytes(), again.NodeKey.Bytes())
+
+	for _, invalid := range []string{
+		`{"publicListenAddr": ":3001"}`,
+		`{"listenAddr": ":3000", "logLevel": "loud"}`,
+		`{"listenAddr": ":3000", "advertiseAddr": ":3000"}`,
+		`{"listenAddr": ":3000", "advertiseAddr": "node-1.example"}`,
+		`{"listenAddr": ":3000", "snapshotFile": "snapshot"}`,
+	} {
+		require.Nil(t, os.WriteFile(cfgFile, []byte(invalid), 0600))
+		_, err = LoadConfig(cfgFile)
+		assert.NotNil(t, err, invalid)
+	}
+}
@@ -0,0 +1,153 @@
+package node
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+)
+
+const (
+	TopicBlock       = "block"
+	TopicTx          = "tx"
+	TopicTxConfirmed = "tx_confirmed"
+	TopicAddress     = "address"
+	TopicReorg       = "reorg"
+
+	TopicPeerConnected    = "peer_connected"
+	TopicPeerDisconnected = "peer_disconnected"
+)
+
+const subscriptionBuffer = 256
+
+// EventFilter decides which events a subscriber gets. Empty topics means every topic,
+// empty addresses means address events for every address.
+type EventFilter struct {
+	Topics    map[string]bool
+	Addresses map[string]bool // hex encoded
+}
+
+func NewEventFilter(topics []string, addresses [][]byte) EventFilter {
+	filter := EventFilter{
+		Topics:    make(map[string]bool),
+		Addresses: make(map[string]bool),
+	}
+	for _, topic := range topics {
+		filter.Topics[topic] = true
+	}
+	for _, address := range addresses {
+		filter.Addresses[hex.EncodeToString(address)] = true
+	}
+	return filter
+}
+
+func (f EventFilter) Match(e *proto.Event) bool {
+	if len(f.Topics) > 0 && !f.Topics[e.Topic] {
+		return false
+	}
+	if e.Topic == TopicAddress && len(f.Addresses) > 0 {
+		return f.Addresses[hex.EncodeToString(e.Address)]
+	}
+	return true
+}
+
+type Subscription struct {
+	C      chan *proto.Event // closed when we unsubscribe, or when the subscriber can't keep up.
+	filter EventFilter
+}
+
+// EventBus fans out the events of the chain and the mempool to every subscriber.
+// Publishing never blocks: a subscriber that doesn't keep up gets dropped.
+type EventBus struct {
+	lock sync.Mutex
+	subs map[*Subscription]bool
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subs: make(map[*Subscription]bool),
+	}
+}
+
+func (bus *EventBus) Subscribe(filter EventFilter) *Subscription {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	sub := &Subscription{
+		C:      make(chan *proto.Event, subscriptionBuffer),
+		filter: filter,
+	}
+	bus.subs[sub] = true
+	return sub
+}
+
+func (bus *EventBus) Unsubscribe(sub *Subscription) {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	if bus.subs[sub] {
+		delete(bus.subs, sub)
+		close(sub.C)
+	}
+}
+
+func (bus *EventBus) Publish(e *proto.Event) {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	for sub := range bus.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.C <- e:
+		default:
+			delete(bus.subs, sub)
+			close(sub.C)
+		}
+	}
+}
+
+// AddBlock publishes the events of a block added to the chain, EventBus is an Indexer.
+func (bus *EventBus) AddBlock(b *proto.Block, height int, spent []*UTXO) error {
+	bus.Publish(&proto.Event{
+		Topic:  TopicBlock,
+		Height: int32(height),
+		Hash:   types.HashBlock(b),
+		Block:  b,
+	})
+	for _, tx := range b.Transactions {
+		hash := types.HashTransaction(tx)
+		bus.Publish(&proto.Event{
+			Topic:       TopicTxConfirmed,
+			Height:      int32(height),
+			Hash:        hash,
+			Transaction: tx,
+		})
+		for _, output := range tx.Outputs {
+			if types.IsDataOutput(output) {
+				continue
+			}
+			bus.Publish(&proto.Event{
+				Topic:   TopicAddress,
+				Height:  int32(height),
+				Hash:    hash,
+				Address: output.Address,
+				Amount:  output.Amount,
+				Asset:   output.Asset,
+			})
+		}
+	}
+	return nil
+}
+
+func (bus *EventBus) RollbackBlock(b *proto.Block, height int) error {
+	bus.Publish(&proto.Event{
+		Topic:  TopicReorg,
+		Height: int32(height),
+		Hash:   types.HashBlock(b),
+		Block:  b,
+	})
+	return nil
+}
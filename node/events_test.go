@@ -0,0 +1,72 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBusChainEvents(t *testing.T) {
+	var (
+		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+		bus       = NewEventBus()
+		recipient = crypto.GeneratePrivateKey().Public().Address().Bytes()
+		all       = bus.Subscribe(NewEventFilter(nil, nil))
+		ours      = bus.Subscribe(NewEventFilter([]string{TopicAddress}, [][]byte{recipient}))
+	)
+	chain.Notify(bus)
+
+	block := randomBlock(t, chain)
+	tx := spendGenesisTx(t, chain, recipient, 100)
+	block.Transactions = append(block.Transactions, tx)
+	signBlock(t, chain, crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	// block, tx_confirmed, address (recipient), address (change)
+	topics := []string{}
+	for i := 0; i < 4; i++ {
+		topics = append(topics, (<-all.C).Topic)
+	}
+	assert.Equal(t, []string{TopicBlock, TopicTxConfirmed, TopicAddress, TopicAddress}, topics)
+
+	e := <-ours.C
+	assert.Equal(t, recipient, e.Address)
+	assert.Equal(t, uint64(100), e.Amount)
+	assert.Equal(t, types.HashTransaction(tx), e.Hash)
+	assert.Equal(t, 0, len(ours.C)) // Not the change output of the devSeed address.
+
+	require.Nil(t, chain.RollbackBlock())
+	e = <-all.C
+	assert.Equal(t, TopicReorg, e.Topic)
+	assert.Equal(t, types.HashBlock(block), e.Hash)
+
+	bus.Unsubscribe(all)
+	_, ok := <-all.C
+	assert.False(t, ok)
+}
+
+func TestEventBusMempoolAndSlowSubscriber(t *testing.T) {
+	var (
+		bus  = NewEventBus()
+		pool = NewMempool()
+		sub  = bus.Subscribe(NewEventFilter([]string{TopicTx}, nil))
+	)
+	pool.events = bus
+
+	for i := 0; i < subscriptionBuffer; i++ {
+		assert.True(t, pool.Add(&proto.Transaction{Version: int32(i)}))
+	}
+	assert.False(t, pool.Add(&proto.Transaction{Version: 0})) // Already in the pool, no event.
+	assert.Equal(t, subscriptionBuffer, len(sub.C))
+
+	// The buffer is full, the next event drops the subscriber instead of blocking the mempool.
+	pool.Add(&proto.Transaction{Version: -1})
+	for range sub.C {
+	}
+	_, ok := <-sub.C
+	assert.False(t, ok)
+}
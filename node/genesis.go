@@ -0,0 +1,201 @@
+package node
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+)
+
+// devSeed is the seed of the key of the coins of the dev network (DefaultGenesis). Everybody knows it,
+// so never use the dev network for anything of value.
+const devSeed = "printedHashOnTheTerminalViafmt.PrintlnFromTheSeedVaraibleInTheFileMain.goInMainFunc" // It is to make deterministic privateKey so we can have coins or some kind of a genesis input. The output that we can use as input in our transactions.
+
+// devKey returns the key of devSeed. The seed isn't hex, so we hash it into the 32 bytes of a key seed.
+func devKey() *crypto.PrivateKey {
+	seed := sha256.Sum256([]byte(devSeed))
+	return crypto.NewPrivateKeyFromSeed(seed[:])
+}
+
+type GenesisAllocation struct {
+	Address string `json:"address"` // hex
+	Amount  uint64 `json:"amount"`
+}
+
+type ConsensusParams struct {
+	BlockTime   int `json:"blockTime"`   // milliseconds between blocks.
+	MaxBlockTxs int `json:"maxBlockTxs"` // 0 means no limit.
+}
+
+// Genesis describes block 0 of a network. Every node of the network needs the same genesis file,
+// the genesis block is built from it and nothing else, so they all end up with the same block.
+type Genesis struct {
+	ChainID     string              `json:"chainId"`
+	Timestamp   int64               `json:"timestamp"` // unix seconds
+	Allocations []GenesisAllocation `json:"allocations"`
+	Validators  []string            `json:"validators"` // hex public keys of who can sign blocks, empty means anybody.
+	Consensus   ConsensusParams     `json:"consensus"`
+}
+
+// DefaultGenesis is the dev network, for running nodes locally and for our tests.
+func DefaultGenesis() *Genesis {
+	return &Genesis{
+		ChainID: "blocker-dev",
+		Allocations: []GenesisAllocation{
+			{
+				Address: devKey().Public().Address().String(),
+				Amount:  1000,
+			},
+		},
+		Consensus: ConsensusParams{
+			BlockTime: 5000,
+		},
+	}
+}
+
+func LoadGenesis(path string) (*Genesis, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	g := &Genesis{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields() // A typo in a genesis file would silently split the network.
+	if err := dec.Decode(g); err != nil {
+		return nil, fmt.Errorf("invalid genesis file %s: %w", path, err)
+	}
+	if err := g.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid genesis file %s: %w", path, err)
+	}
+	return g, nil
+}
+
+// Validate checks the genesis and makes every hex string lowercase, so the same
+// genesis always gives the same block.
+func (g *Genesis) Validate() error {
+	if g.ChainID == "" {
+		return fmt.Errorf("chainId is required")
+	}
+	if len(g.Allocations) == 0 {
+		return fmt.Errorf("at least 1 allocation is required")
+	}
+	for i, alloc := range g.Allocations {
+		address, err := hex.DecodeString(alloc.Address)
+		if err != nil || len(address) != crypto.AddressLen {
+			return fmt.Errorf("invalid allocation address %q", alloc.Address)
+		}
+		if alloc.Amount == 0 {
+			return fmt.Errorf("allocation to %s has no amount", alloc.Address)
+		}
+		g.Allocations[i].Address = hex.EncodeToString(address)
+	}
+	for i, validator := range g.Validators {
+		pubKey, err := hex.DecodeString(validator)
+		if err != nil || len(pubKey) != crypto.PubKeyLen {
+			return fmt.Errorf("invalid validator public key %q", validator)
+		}
+		g.Validators[i] = hex.EncodeToString(pubKey)
+	}
+	if g.Consensus.BlockTime <= 0 {
+		return fmt.Errorf("consensus blockTime must be positive, got (%d)", g.Consensus.BlockTime)
+	}
+	if g.Consensus.MaxBlockTxs < 0 {
+		return fmt.Errorf("consensus maxBlockTxs cannot be negative, got (%d)", g.Consensus.MaxBlockTxs)
+	}
+	return nil
+}
+
+// Hash commits to everything in the genesis, not only the allocations. The genesis block
+// carries it in a data output, so two networks with different params never share a genesis block.
+// Genesis files that mean the same hash the same, whether they leave out an empty list or not
+// and whatever the case of their hex.
+func (g *Genesis) Hash() []byte {
+	b, err := json.Marshal(g.canonical())
+	if err != nil {
+		panic(err)
+	}
+	hash := sha256.Sum256(b)
+	return hash[:]
+}
+
+// canonical returns a copy of the genesis with lowercase hex and nil for empty lists.
+func (g *Genesis) canonical() *Genesis {
+	c := *g
+	c.Allocations = nil
+	for _, alloc := range g.Allocations {
+		alloc.Address = strings.ToLower(alloc.Address)
+		c.Allocations = append(c.Allocations, alloc)
+	}
+	c.Validators = nil
+	for _, validator := range g.Validators {
+		c.Validators = append(c.Validators, strings.ToLower(validator))
+	}
+	return &c
+}
+
+func (g *Genesis) BlockTime() time.Duration {
+	return time.Duration(g.Consensus.BlockTime) * time.Millisecond
+}
+
+// IsValidator reports whether the owner of pubKey can sign blocks.
+func (g *Genesis) IsValidator(pubKey []byte) bool {
+	if len(g.Validators) == 0 {
+		return true
+	}
+	key := hex.EncodeToString(pubKey)
+	for _, validator := range g.Validators {
+		if validator == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Block builds the genesis block. Nobody signs it, it is valid because everybody builds the same one.
+func (g *Genesis) Block() (*proto.Block, error) {
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs:  []*proto.TxInput{}, // The coins of the genesis block are created out of thin air.
+	}
+	for _, alloc := range g.Allocations {
+		address, err := hex.DecodeString(alloc.Address)
+		if err != nil {
+			return nil, err
+		}
+		tx.Outputs = append(tx.Outputs, &proto.TxOutput{
+			Amount:  alloc.Amount,
+			Address: address,
+		})
+	}
+	tx.Outputs = append(tx.Outputs, &proto.TxOutput{
+		Data: g.Hash(),
+	})
+
+	block := &proto.Block{
+		Header: &proto.Header{
+			Version:   1,
+			Timestamp: g.Timestamp * int64(time.Second),
+		},
+		Transactions: []*proto.Transaction{tx},
+	}
+	block.Header.RootHash = types.CalculateRootHash(block)
+	set := newUTXOSet()
+	if err := set.applyBlock(block, func(key string) (*UTXO, error) {
+		return nil, fmt.Errorf("no utxos before the genesis block")
+	}); err != nil {
+		return nil, err
+	}
+	block.Header.StateRoot = set.root()
+	return block, nil
+}
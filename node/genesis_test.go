@@ -0,0 +1,133 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenesisBlockIsDeterministic(t *testing.T) {
+	a := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	b := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	assert.Equal(t, a.GenesisHash(), b.GenesisHash())
+
+	genesis, err := a.GetBlockByHeight(0)
+	require.Nil(t, err)
+	assert.Equal(t, types.HashBlock(genesis), a.GenesisHash())
+
+	// Any param makes it another network.
+	other := DefaultGenesis()
+	other.Consensus.MaxBlockTxs = 10
+	c := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), other)
+	assert.NotEqual(t, a.GenesisHash(), c.GenesisHash())
+
+	// Which means we don't take a snapshot of it either.
+	snap, err := c.Snapshot(1)
+	require.Nil(t, err)
+	_, err = NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis(), snap, nil)
+	assert.NotNil(t, err)
+}
+
+func TestLoadGenesis(t *testing.T) {
+	var (
+		file      = filepath.Join(t.TempDir(), "genesis.json")
+		address   = crypto.GeneratePrivateKey().Public().Address().String()
+		validator = hex.EncodeToString(crypto.GeneratePrivateKey().Public().Bytes())
+	)
+	require.Nil(t, os.WriteFile(file, []byte(`{
+		"chainId": "blocker-test",
+		"timestamp": 1700000000,
+		"allocations": [{"address": "`+strings.ToUpper(address)+`", "amount": 500}],
+		"validators": ["`+validator+`"],
+		"consensus": {"blockTime": 2000, "maxBlockTxs": 100}
+	}`), 0600))
+	genesis, err := LoadGenesis(file)
+	require.Nil(t, err)
+	assert.Equal(t, address, genesis.Allocations[0].Address) // Hex is always lowercase.
+
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), genesis)
+	block, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1700000000)*1e9, block.Header.Timestamp)
+	balance, err := chain.GetBalance(crypto.AddressFromBytes(mustDecodeHex(t, address)).Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, uint64(500), balance[NativeAsset])
+
+	for _, invalid := range []string{
+		`{"chainId": "x", "allocations": [{"address": "abcd", "amount": 1}], "consensus": {"blockTime": 1}}`,
+		`{"chainId": "x", "allocations": [{"address": "` + address + `", "amount": 1}], "consensus": {"blockTime": 0}}`,
+		`{"allocations": [{"address": "` + address + `", "amount": 1}], "consensus": {"blockTime": 1}}`,
+		`{"chainId": "x", "allocation": [{"address": "` + address + `", "amount": 1}], "consensus": {"blockTime": 1}}`,
+	} {
+		require.Nil(t, os.WriteFile(file, []byte(invalid), 0600))
+		_, err := LoadGenesis(file)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestEquivalentGenesisFilesHaveTheSameBlock(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		address = crypto.GeneratePrivateKey().Public().Address().String()
+		hashes  [][]byte
+	)
+	for i, content := range []string{
+		`{"chainId": "x", "allocations": [{"address": "` + address + `", "amount": 1}], "consensus": {"blockTime": 1}}`,
+		`{"chainId": "x", "allocations": [{"address": "` + address + `", "amount": 1}], "validators": [], "consensus": {"blockTime": 1}}`,
+		`{"chainId": "x", "allocations": [{"address": "` + strings.ToUpper(address) + `", "amount": 1}], "validators": null, "consensus": {"blockTime": 1}}`,
+	} {
+		file := filepath.Join(dir, fmt.Sprintf("genesis-%d.json", i))
+		require.Nil(t, os.WriteFile(file, []byte(content), 0600))
+		genesis, err := LoadGenesis(file)
+		require.Nil(t, err)
+		hashes = append(hashes, NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), genesis).GenesisHash())
+	}
+	assert.Equal(t, hashes[0], hashes[1])
+	assert.Equal(t, hashes[0], hashes[2])
+
+	// Before Validate too.
+	genesis := DefaultGenesis()
+	hash := genesis.Hash()
+	genesis.Validators = []string{}
+	genesis.Allocations[0].Address = strings.ToUpper(genesis.Allocations[0].Address)
+	assert.Equal(t, hash, genesis.Hash())
+}
+
+func TestValidatorSetAndMaxBlockTxs(t *testing.T) {
+	var (
+		validator = crypto.GeneratePrivateKey()
+		genesis   = DefaultGenesis()
+	)
+	genesis.Validators = []string{hex.EncodeToString(validator.Public().Bytes())}
+	genesis.Consensus.MaxBlockTxs = 1
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), genesis)
+
+	// randomBlock signs with a random key.
+	assert.NotNil(t, chain.AddBlock(randomBlock(t, chain)))
+
+	block := randomBlock(t, chain)
+	signBlock(t, chain, validator, block)
+	require.Nil(t, chain.AddBlock(block))
+
+	block = randomBlock(t, chain)
+	recipient := crypto.GeneratePrivateKey().Public().Address().Bytes()
+	block.Transactions = append(block.Transactions, spendGenesisTx(t, chain, recipient, 1), spendGenesisTx(t, chain, recipient, 2))
+	types.SignBlock(validator, block) // Too many txs is checked before the txs themselves.
+	err := chain.AddBlock(block)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "the max is (1)")
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.Nil(t, err)
+	return b
+}
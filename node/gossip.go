@@ -0,0 +1,235 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+)
+
+// Gossip goes by inventory. We announce the hashes of new txs and blocks to our peers with an Inv,
+// batched every invInterval, and they fetch the ones they don't have with a GetDataRequest. Every peer has a
+// set of the hashes it knows, the ones it announced to us and the ones we announced to it. Those we never send it.
+//
+// Every peer has its own sendLoop, so a slow peer only holds up itself. What we announce waits in its queue,
+// a peer that lets it overflow can't keep up with the network and gets disconnected.
+
+const (
+	invInterval     = 100 * time.Millisecond
+	maxInvItems     = 1000 // per Inv and per GetDataRequest.
+	maxKnownInv     = 20000
+	maxSendQueue    = 4 * maxInvItems
+	gossipTimeout   = 10 * time.Second
+	inflightTimeout = 2 * gossipTimeout
+)
+
+// invSet is a set of hashes that forgets the oldest ones when it's full.
+type invSet struct {
+	hashes map[string]bool
+	order  []string // The hashes in the order they were added, a ring once it's full.
+	next   int
+}
+
+func (s *invSet) add(hash []byte) {
+	if s.hashes == nil {
+		s.hashes = make(map[string]bool)
+	}
+	key := string(hash)
+	if s.hashes[key] {
+		return
+	}
+	if len(s.order) < maxKnownInv {
+		s.order = append(s.order, key)
+	} else {
+		delete(s.hashes, s.order[s.next])
+		s.order[s.next] = key
+		s.next = (s.next + 1) % maxKnownInv
+	}
+	s.hashes[key] = true
+}
+
+func (s *invSet) has(hash []byte) bool {
+	return s.hashes[string(hash)]
+}
+
+// addKnown remembers p has the items.
+func (p *remotePeer) addKnown(items []*proto.InvItem) {
+	p.invLock.Lock()
+	defer p.invLock.Unlock()
+
+	for _, item := range items {
+		p.known.add(item.Hash)
+	}
+}
+
+// queueInv adds item to the next Inv we send p, unless p has it already. It reports false when the queue is full.
+func (p *remotePeer) queueInv(item *proto.InvItem) bool {
+	p.invLock.Lock()
+	defer p.invLock.Unlock()
+
+	if p.known.has(item.Hash) {
+		return true
+	}
+	if len(p.pending) >= maxSendQueue {
+		return false
+	}
+	p.pending = append(p.pending, item)
+	return true
+}
+
+// takeInv returns the queued items p doesn't know about. From now on it does.
+func (p *remotePeer) takeInv() []*proto.InvItem {
+	p.invLock.Lock()
+	defer p.invLock.Unlock()
+
+	items := p.pending[:0:0]
+	for _, item := range p.pending {
+		if !p.known.has(item.Hash) {
+			p.known.add(item.Hash)
+			items = append(items, item)
+		}
+	}
+	p.pending = nil
+	return items
+}
+
+func invItem(msg any) *proto.InvItem {
+	switch v := msg.(type) {
+	case *proto.Transaction:
+		return &proto.InvItem{Type: proto.InvType_INV_TX, Hash: types.HashTransaction(v)}
+	case *proto.Block:
+		return &proto.InvItem{Type: proto.InvType_INV_BLOCK, Hash: types.HashBlock(v)}
+	}
+	panic("no inventory for this type")
+}
+
+// announce tells our peers we have the tx or block in msg, with the next Inv.
+func (n *Node) announce(msg any) {
+	item := invItem(msg)
+	for _, p := range n.peerList() {
+		if !p.queueInv(item) {
+			n.removePeer(p, "too slow, send queue full")
+		}
+	}
+}
+
+// handleInv fetches what p announced and we don't have yet.
+func (n *Node) handleInv(p *remotePeer, inv *proto.Inv) error {
+	if len(inv.Items) > maxInvItems {
+		n.misbehaving(p.banAddr(), scoreFlooding, "inv too big")
+		return fmt.Errorf("inv with more than (%d) items", maxInvItems)
+	}
+	p.addKnown(inv.Items)
+
+	var wanted []*proto.InvItem
+	for _, item := range inv.Items {
+		if !n.haveInv(item) && n.request(item) {
+			wanted = append(wanted, item)
+		}
+	}
+	if len(wanted) > 0 {
+		// Not in the readLoop, the data comes in through it.
+		go n.fetch(p, wanted)
+	}
+	return nil
+}
+
+func (n *Node) haveInv(item *proto.InvItem) bool {
+	switch item.Type {
+	case proto.InvType_INV_TX:
+		if _, ok := n.mempool.Get(hex.EncodeToString(item.Hash)); ok {
+			return true
+		}
+		_, _, err := n.chain.GetTransaction(item.Hash)
+		return err == nil
+	case proto.InvType_INV_BLOCK:
+		_, err := n.chain.GetBlockByHash(item.Hash)
+		return err == nil
+	}
+	return true // Nothing we know how to ask for.
+}
+
+// request reports whether we should fetch item. We ask one peer at a time, the next one that announces it
+// only gets asked when that one didn't deliver within the inflightTimeout.
+func (n *Node) request(item *proto.InvItem) bool {
+	n.inflightLock.Lock()
+	defer n.inflightLock.Unlock()
+
+	key := string(item.Hash)
+	if expiry, ok := n.inflight[key]; ok && time.Now().Before(expiry) {
+		return false
+	}
+	n.inflight[key] = time.Now().Add(inflightTimeout)
+	return true
+}
+
+func (n *Node) requestDone(items []*proto.InvItem) {
+	n.inflightLock.Lock()
+	defer n.inflightLock.Unlock()
+
+	for _, item := range items {
+		delete(n.inflight, string(item.Hash))
+	}
+}
+
+// fetch gets the items p announced with a GetDataRequest. They go through the same checks as a pushed tx or block.
+func (n *Node) fetch(p *remotePeer, items []*proto.InvItem) {
+	defer n.requestDone(items)
+
+	ctx, cancel := context.WithTimeout(context.Background(), gossipTimeout)
+	defer cancel()
+	env, err := p.call(ctx, &proto.Envelope{Msg: &proto.Envelope_GetData{GetData: &proto.GetDataRequest{Items: items}}})
+	data := env.GetInvData()
+	if err == nil && data == nil {
+		err = fmt.Errorf("answered getData with %T", env.Msg)
+	}
+	if err != nil {
+		n.logger.Debugw("could not get data", "we", n.ListenAddr, "remoteNode", p.version.ListenAddr, "err", err)
+		return
+	}
+	asked := invSet{}
+	for _, item := range items {
+		asked.add(item.Hash)
+	}
+	from := p.banAddr()
+	for _, tx := range data.Transactions {
+		if !asked.has(types.HashTransaction(tx)) {
+			continue // We didn't ask for it.
+		}
+		if err := n.receiveTransaction(from, tx); err != nil {
+			n.logger.Debugw("rejected tx", "we", n.ListenAddr, "remoteNode", p.version.ListenAddr, "err", err)
+		}
+	}
+	for _, b := range data.Blocks {
+		if b.Header == nil || !asked.has(types.HashBlock(b)) {
+			continue
+		}
+		if err := n.receiveBlock(from, b); err != nil {
+			n.logger.Debugw("rejected block", "we", n.ListenAddr, "remoteNode", p.version.ListenAddr, "err", err)
+		}
+	}
+}
+
+// getData is what we answer a GetDataRequest for items with.
+func (n *Node) getData(items []*proto.InvItem) *proto.InvData {
+	resp := &proto.InvData{}
+	for _, item := range items {
+		switch item.Type {
+		case proto.InvType_INV_TX:
+			if tx, ok := n.mempool.Get(hex.EncodeToString(item.Hash)); ok {
+				resp.Transactions = append(resp.Transactions, tx)
+			} else if tx, _, err := n.chain.GetTransaction(item.Hash); err == nil {
+				resp.Transactions = append(resp.Transactions, tx)
+			}
+		case proto.InvType_INV_BLOCK:
+			// Pruned blocks, like the ones we don't know, are left out.
+			if b, err := n.chain.GetBlockByHash(item.Hash); err == nil && b.Header != nil {
+				resp.Blocks = append(resp.Blocks, b)
+			}
+		}
+	}
+	return resp
+}
@@ -0,0 +1,184 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	"github.com/Fito305/blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvSetForgetsTheOldestHashes(t *testing.T) {
+	s := invSet{}
+	for i := 0; i < maxKnownInv+10; i++ {
+		s.add([]byte(fmt.Sprint(i)))
+	}
+	assert.Len(t, s.hashes, maxKnownInv)
+	assert.False(t, s.has([]byte("9")))
+	assert.True(t, s.has([]byte("10")))
+	assert.True(t, s.has([]byte(fmt.Sprint(maxKnownInv+9))))
+}
+
+func TestPeersOnlyGetInvTheyDontKnow(t *testing.T) {
+	var (
+		p     = &remotePeer{}
+		tx    = &proto.InvItem{Type: proto.InvType_INV_TX, Hash: util.RandomHash()}
+		block = &proto.InvItem{Type: proto.InvType_INV_BLOCK, Hash: util.RandomHash()}
+	)
+	p.addKnown([]*proto.InvItem{tx}) // It announced the tx to us.
+	p.queueInv(tx)
+	p.queueInv(block)
+	p.queueInv(block)
+	assert.Equal(t, []*proto.InvItem{block}, p.takeInv())
+
+	p.queueInv(block)
+	assert.Empty(t, p.takeInv())
+}
+
+func TestGetDataServesMempoolAndChain(t *testing.T) {
+	n := NewNode(ServerConfig{})
+	tx := spendGenesisTx(t, n.chain, util.RandomHash()[:crypto.AddressLen], 10)
+	require.True(t, n.mempool.Add(tx))
+	genesis, err := n.chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+
+	resp := n.getData([]*proto.InvItem{
+		{Type: proto.InvType_INV_TX, Hash: types.HashTransaction(tx)},
+		{Type: proto.InvType_INV_TX, Hash: types.HashTransaction(genesis.Transactions[0])},
+		{Type: proto.InvType_INV_TX, Hash: util.RandomHash()},
+		{Type: proto.InvType_INV_BLOCK, Hash: types.HashBlock(genesis)},
+		{Type: proto.InvType_INV_BLOCK, Hash: util.RandomHash()},
+	})
+	assert.Len(t, resp.Transactions, 2)
+	require.Len(t, resp.Blocks, 1)
+	assert.Equal(t, types.HashBlock(genesis), types.HashBlock(resp.Blocks[0]))
+}
+
+func TestTxsAndBlocksSpreadWithInvAndGetData(t *testing.T) {
+	var (
+		addrA = freeAddr(t)
+		addrB = freeAddr(t)
+		addrC = freeAddr(t)
+		a     = NewNode(ServerConfig{})
+		b     = NewNode(ServerConfig{})
+		c     = NewNode(ServerConfig{})
+	)
+	defer a.Stop()
+	defer b.Stop()
+	defer c.Stop()
+
+	go a.Start(addrA, nil)
+	waitForListener(t, addrA)
+	go b.Start(addrB, []string{addrA})
+	waitForListener(t, addrB)
+	go c.Start(addrC, []string{addrB})
+	require.Eventually(t, func() bool {
+		return len(b.getPeerList()) == 2
+	}, 3*time.Second, 10*time.Millisecond)
+
+	tx := spendGenesisTx(t, a.chain, util.RandomHash()[:crypto.AddressLen], 10)
+	require.Nil(t, a.submitTransaction(tx))
+	require.Eventually(t, func() bool {
+		return b.mempool.Has(tx) && c.mempool.Has(tx)
+	}, 3*time.Second, 10*time.Millisecond)
+
+	block := randomBlock(t, c.chain)
+	require.Nil(t, c.receiveBlock("", block))
+	require.Eventually(t, func() bool {
+		return height(a) == 1 && height(b) == 1
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// In the end everybody knows who has what, so nothing gets announced again.
+	require.Eventually(t, func() bool {
+		for _, n := range []*Node{a, b, c} {
+			for _, p := range n.peerList() {
+				p.invLock.Lock()
+				known := p.known.has(types.HashTransaction(tx)) && p.known.has(types.HashBlock(block))
+				p.invLock.Unlock()
+				if !known {
+					return false
+				}
+			}
+		}
+		return true
+	}, time.Second, 10*time.Millisecond)
+}
+
+// height reads the height of the chain of n while blocks come in. The chain has no lock of its own, the blockLock keeps blocks out.
+func height(n *Node) int {
+	n.blockLock.Lock()
+	defer n.blockLock.Unlock()
+	return n.chain.Height()
+}
+
+// invCounter counts the items a peer gets announced.
+type invCounter struct {
+	lock  sync.Mutex
+	items int
+}
+
+func (c *invCounter) count() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.items
+}
+
+// addPipePeer connects a peer to n over a pipe. It counts the items n announces to it, a stuck one never reads.
+func addPipePeer(t *testing.T, n *Node, stuck bool) *invCounter {
+	c := &invCounter{}
+	_, pipe, err := connectPipe(t, context.Background(), n, remoteVersion(n, crypto.GeneratePrivateKey(), freeAddr(t)))
+	require.Nil(t, err)
+	if stuck {
+		return c
+	}
+	go func() {
+		for {
+			env, err := pipe.Recv()
+			if err != nil {
+				return
+			}
+			if inv := env.GetInv(); inv != nil {
+				c.lock.Lock()
+				c.items += len(inv.Items)
+				c.lock.Unlock()
+			}
+		}
+	}()
+	return c
+}
+
+func TestStuckPeersGetDroppedWithoutStallingTheOthers(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{})
+		_       = addPipePeer(t, n, true)
+		healthy = addPipePeer(t, n, false)
+	)
+	defer n.Stop()
+
+	announced := 0
+	announce := func(count int) {
+		for i := 0; i < count; i++ {
+			n.announce(&proto.Transaction{Version: 1, Inputs: []*proto.TxInput{{PrevTxHash: util.RandomHash()}}})
+		}
+		announced += count
+	}
+	announce(1)
+	// The stuck peer blocks its sendLoop, what we announce next piles up in its queue.
+	require.Eventually(t, func() bool {
+		return healthy.count() == announced
+	}, time.Second, 10*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		announce(maxSendQueue/2 + 1)
+		require.Eventually(t, func() bool {
+			return healthy.count() == announced
+		}, time.Second, 10*time.Millisecond)
+	}
+	assert.Len(t, n.getPeerList(), 1)
+}
@@ -0,0 +1,139 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+)
+
+// An Indexer is fed every block the chain adds and every block it rolls back, so it can
+// build extra lookups (like the history of an address) next to the chain.
+// spent are the utxos the block spent, as they were before the block.
+type Indexer interface {
+	AddBlock(b *proto.Block, height int, spent []*UTXO) error
+	RollbackBlock(b *proto.Block, height int) error
+}
+
+// HistoryEntry is the net amount of one asset a transaction moved to or from an address.
+type HistoryEntry struct {
+	TxHash string
+	Height int
+	Asset  string
+	Amount int64 // negative when the address spent more than it received.
+}
+
+const maxHistoryPageSize = 100
+
+// MemoryHistoryIndex keeps the history of every address that ever received or spent funds.
+type MemoryHistoryIndex struct {
+	lock    sync.RWMutex
+	entries map[string][]*HistoryEntry // address => entries sorted from oldest to newest.
+	touched map[int][]string           // height => addresses the block at that height added entries to.
+}
+
+func NewMemoryHistoryIndex() *MemoryHistoryIndex {
+	return &MemoryHistoryIndex{
+		entries: make(map[string][]*HistoryEntry),
+		touched: make(map[int][]string),
+	}
+}
+
+func (idx *MemoryHistoryIndex) AddBlock(b *proto.Block, height int, spent []*UTXO) error {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	spentByKey := make(map[string]*UTXO, len(spent))
+	for _, utxo := range spent {
+		spentByKey[utxoKey(utxo.Hash, utxo.OutIndex)] = utxo
+	}
+
+	for _, tx := range b.Transactions {
+		hash := hex.EncodeToString(types.HashTransaction(tx))
+		// address => asset => net amount
+		net := map[string]map[string]int64{}
+		add := func(address string, asset string, amount int64) {
+			if _, ok := net[address]; !ok {
+				net[address] = map[string]int64{}
+			}
+			net[address][asset] += amount
+		}
+		for _, input := range tx.Inputs {
+			utxo, ok := spentByKey[utxoKey(hex.EncodeToString(input.PrevTxHash), int(input.PrevOutIndex))]
+			if !ok {
+				return fmt.Errorf("no spent utxo for input of tx %s", hash)
+			}
+			add(utxo.Address, utxo.Asset, -int64(utxo.Amount))
+		}
+		for _, output := range tx.Outputs {
+			if types.IsDataOutput(output) {
+				continue
+			}
+			add(hex.EncodeToString(output.Address), hex.EncodeToString(output.Asset), int64(output.Amount))
+		}
+
+		// Sort so the entries of a tx always end up in the same order.
+		addresses := make([]string, 0, len(net))
+		for address := range net {
+			addresses = append(addresses, address)
+		}
+		sort.Strings(addresses)
+		for _, address := range addresses {
+			assets := make([]string, 0, len(net[address]))
+			for asset := range net[address] {
+				assets = append(assets, asset)
+			}
+			sort.Strings(assets)
+			for _, asset := range assets {
+				idx.entries[address] = append(idx.entries[address], &HistoryEntry{
+					TxHash: hash,
+					Height: height,
+					Asset:  asset,
+					Amount: net[address][asset],
+				})
+			}
+			idx.touched[height] = append(idx.touched[height], address)
+		}
+	}
+	return nil
+}
+
+// RollbackBlock removes the entries of the block at height. Blocks are always rolled back
+// from the tip, so they are the last entries of each address.
+func (idx *MemoryHistoryIndex) RollbackBlock(b *proto.Block, height int) error {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	for _, address := range idx.touched[height] {
+		entries := idx.entries[address]
+		for len(entries) > 0 && entries[len(entries)-1].Height == height {
+			entries = entries[:len(entries)-1]
+		}
+		if len(entries) == 0 {
+			delete(idx.entries, address)
+			continue
+		}
+		idx.entries[address] = entries
+	}
+	delete(idx.touched, height)
+	return nil
+}
+
+// GetHistory returns a page of the history of the address, newest first, and the total number of entries.
+func (idx *MemoryHistoryIndex) GetHistory(address []byte, offset int, limit int) ([]*HistoryEntry, int) {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	if limit <= 0 || limit > maxHistoryPageSize {
+		limit = maxHistoryPageSize
+	}
+	entries := idx.entries[hex.EncodeToString(address)]
+	page := []*HistoryEntry{}
+	for i := len(entries) - 1 - offset; i >= 0 && len(page) < limit && offset >= 0; i-- {
+		page = append(page, entries[i])
+	}
+	return page, len(entries)
+}
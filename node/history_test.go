@@ -0,0 +1,56 @@
+package node
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressHistory(t *testing.T) {
+	var (
+		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+		history   = NewMemoryHistoryIndex()
+		privKey   = crypto.GeneratePrivateKey()
+		godAddr   = devKey().Public().Address().Bytes()
+		recipient = crypto.GeneratePrivateKey().Public().Address().Bytes()
+	)
+	require.Nil(t, chain.AddIndexer(history))
+
+	// The genesis block was indexed when we added the indexer.
+	entries, total := history.GetHistory(godAddr, 0, 10)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, int64(1000), entries[0].Amount)
+
+	block := randomBlock(t, chain)
+	tx := spendGenesisTx(t, chain, recipient, 100)
+	block.Transactions = append(block.Transactions, tx)
+	signBlock(t, chain, privKey, block)
+	require.Nil(t, chain.AddBlock(block))
+
+	entries, total = history.GetHistory(godAddr, 0, 10)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, int64(-100), entries[0].Amount) // Newest first, we spent 1000 and got 900 back.
+	assert.Equal(t, 1, entries[0].Height)
+	assert.Equal(t, hex.EncodeToString(types.HashTransaction(tx)), entries[0].TxHash)
+
+	entries, total = history.GetHistory(recipient, 0, 10)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, int64(100), entries[0].Amount)
+
+	// Pagination
+	entries, _ = history.GetHistory(godAddr, 1, 10)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, int64(1000), entries[0].Amount)
+	entries, _ = history.GetHistory(godAddr, 2, 10)
+	assert.Equal(t, 0, len(entries))
+
+	require.Nil(t, chain.RollbackBlock())
+	_, total = history.GetHistory(recipient, 0, 10)
+	assert.Equal(t, 0, total)
+	_, total = history.GetHistory(godAddr, 0, 10)
+	assert.Equal(t, 1, total)
+}
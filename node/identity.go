@@ -0,0 +1,79 @@
+package node
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+)
+
+// Every node has an ed25519 node key, its nodeId is the public key. In the handshake both sides prove they own
+// the key of the nodeId they claim: the caller signs the nonce the responder opens Connect with, the responder
+// signs the challenge the caller sent along. So nobody can take the nodeId of a node we are already connected to.
+
+const (
+	nodeKeyFileName = "node.key"
+	challengeLen    = 32
+)
+
+// LoadOrCreateNodeKey reads the node key at path. The first time there is none, it creates one.
+func LoadOrCreateNodeKey(path string) (*crypto.PrivateKey, error) {
+	key, err := crypto.ReadPrivateKeyFile(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return key, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	key = crypto.GeneratePrivateKey()
+	if err := crypto.WritePrivateKeyFile(path, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// handshakeMessage is what a node signs in the handshake. It binds the nonce of the other side to who we are,
+// where to dial us and which network we are on, so the signature is good for nothing else.
+func handshakeMessage(nonce []byte, v *proto.Version) []byte {
+	h := sha256.New()
+	h.Write([]byte("blocker handshake"))
+	for _, b := range [][]byte{nonce, v.NodeId, []byte(v.ListenAddr), []byte(v.ChainId), v.GenesisHash, []byte(v.ObservedIp), v.Challenge} {
+		binary.Write(h, binary.BigEndian, uint32(len(b)))
+		h.Write(b)
+	}
+	binary.Write(h, binary.BigEndian, v.ProtocolVersion)
+	return h.Sum(nil)
+}
+
+// signVersion answers the challenge nonce of the other side with v, which must not change afterwards.
+func signVersion(key *crypto.PrivateKey, nonce []byte, v *proto.Version) {
+	v.Nonce = nonce
+	v.Signature = key.Sign(handshakeMessage(nonce, v)).Bytes()
+}
+
+// verifyVersion checks v answers our challenge nonce and is signed by the key of its nodeId.
+func verifyVersion(nonce []byte, v *proto.Version) error {
+	pubKey, err := crypto.PublicKeyFromBytes(v.NodeId)
+	if err != nil {
+		return errors.New("invalid node id")
+	}
+	sig, err := crypto.SignatureFromBytes(v.Signature)
+	if err != nil || !bytes.Equal(v.Nonce, nonce) {
+		return errors.New("handshake not signed")
+	}
+	if !sig.Verify(pubKey, handshakeMessage(nonce, v)) {
+		return errors.New("invalid handshake signature")
+	}
+	return nil
+}
+
+func nodeIDString(nodeID []byte) string {
+	return hex.EncodeToString(nodeID)
+}
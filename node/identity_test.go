@@ -0,0 +1,99 @@
+package node
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLoadOrCreateNodeKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", nodeKeyFileName)
+	key, err := LoadOrCreateNodeKey(path)
+	require.Nil(t, err)
+	again, err := LoadOrCreateNodeKey(path)
+	require.Nil(t, err)
+	assert.Equal(t, key.Bytes(), again.Bytes())
+
+	n := NewNode(ServerConfig{NodeKey: key})
+	assert.Equal(t, key.Public().Bytes(), n.getVersion().NodeId)
+}
+
+func TestHandshakeProvesKeyOwnership(t *testing.T) {
+	var (
+		n      = NewNode(ServerConfig{ListenAddr: "127.0.0.1:3000"})
+		ctx    = context.Background()
+		key    = crypto.GeneratePrivateKey()
+		other  = crypto.GeneratePrivateKey()
+		signed *proto.Version
+	)
+	sign := remoteVersion(n, key, "127.0.0.1:4000")
+	resp, _, err := connectPipe(t, ctx, n, func(nonce []byte) *proto.Version {
+		signed = sign(nonce)
+		return signed
+	})
+	require.Nil(t, err)
+	// We proved we own our key too.
+	assert.Nil(t, verifyVersion(signed.Challenge, resp))
+	assert.Equal(t, n.nodeID, resp.NodeId)
+
+	// Every Connect has its own challenge, a signed version is good for one handshake.
+	_, _, err = connectPipe(t, ctx, n, func([]byte) *proto.Version {
+		return signed
+	})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Claiming the nodeId of a node we are connected to, without its key.
+	_, err = handshake(t, ctx, n, other, "127.0.0.1:5000", func(v *proto.Version) {
+		v.NodeId = key.Public().Bytes()
+	})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Changing the version after it was signed.
+	_, _, err = connectPipe(t, ctx, n, func(nonce []byte) *proto.Version {
+		v := remoteVersion(n, other, "127.0.0.1:5000")(nonce)
+		v.ListenAddr = "127.0.0.1:6000"
+		return v
+	})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = handshake(t, ctx, n, other, "127.0.0.1:5000", func(v *proto.Version) {
+		v.Challenge = nil
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, []string{"127.0.0.1:4000"}, n.getPeerList())
+}
+
+func TestPeersAreKnownByNodeID(t *testing.T) {
+	var (
+		addrA = freeAddr(t)
+		addrB = freeAddr(t)
+		a     = NewNode(ServerConfig{})
+		b     = NewNode(ServerConfig{})
+	)
+	defer a.Stop()
+	defer b.Stop()
+
+	go a.Start(addrA, nil)
+	waitForListener(t, addrA)
+	go b.Start(addrB, []string{addrA})
+	require.Eventually(t, func() bool {
+		return len(a.getPeerList()) == 1 && len(b.getPeerList()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	peers, err := a.GetPeers(context.Background(), &proto.PeersRequest{})
+	require.Nil(t, err)
+	require.Len(t, peers.Peers, 1)
+	assert.Equal(t, b.nodeID, peers.Peers[0].NodeId)
+
+	// b dialing a again is a duplicate connection.
+	assert.Equal(t, codes.AlreadyExists, status.Code(b.connect("localhost"+addrA[len("127.0.0.1"):])))
+	assert.Len(t, b.getPeerList(), 1)
+}
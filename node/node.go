@@ -1,29 +1,38 @@
 package node
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/hex"
-	// "fmt"
+	"errors"
+	"fmt"
 	"net"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/Fito305/blocker/crypto"
 	"github.com/Fito305/blocker/proto"
 	"github.com/Fito305/blocker/types"
+	"github.com/Fito305/blocker/util"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
-const blockTime = time.Second * 5
-
 // A Mempool is just a pool in memory of known transactions. For example, if we are playing a game, and we are playing a numbers game, if I'm telling you numbers and we need to go from 1 - 10 but you cannot have any duplicates. What are you going to do, you are going to remember the numbers you choose because you cannot choose the same one again.
 // So a Mempool is each time I'm sending a transaction, I'm going to remember that transaction in my memory. So the next time some other dude is sending me the same transaction, because it's a peer to peer protocol it could be that there is some delay and I already recieved a transaction from Bob but Alice transaction takes a longer round trip, I aleady have a transaction from Bob so i don't need to have the same transaction from alice so I can just drop it.
 // You can make a Mempool as compact as you want.
 type Mempool struct {
-	lock sync.RWMutex
-	txx map[string]*proto.Transaction
+	lock   sync.RWMutex
+	txx    map[string]*proto.Transaction
+	events *EventBus // optional, gets a TopicTx event for every new tx.
 }
 
 func NewMempool() *Mempool {
@@ -46,6 +55,23 @@ func (pool *Mempool) Clear() []*proto.Transaction { // We are going to clear the
 	return txx 
 }
 
+// List returns the transactions in the mempool sorted by hash, without removing them.
+func (pool *Mempool) List() []*proto.Transaction {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	hashes := make([]string, 0, len(pool.txx))
+	for hash := range pool.txx {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	txx := make([]*proto.Transaction, len(hashes))
+	for i, hash := range hashes {
+		txx[i] = pool.txx[hash]
+	}
+	return txx
+}
+
 func (pool *Mempool) Len() int {
 	pool.lock.RLock()
 	defer pool.lock.RUnlock()
@@ -63,6 +89,14 @@ func (pool *Mempool) Has(tx *proto.Transaction) bool {
 	return ok
 }
 
+func (pool *Mempool) Get(hash string) (*proto.Transaction, bool) {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	tx, ok := pool.txx[hash]
+	return tx, ok
+}
+
 func (pool *Mempool) Add(tx *proto.Transaction) bool {
 	if pool.Has(tx) {
 		return false // if we already have it return false because we did Add() it.
@@ -71,15 +105,78 @@ func (pool *Mempool) Add(tx *proto.Transaction) bool {
 	pool.lock.Lock()
 	defer pool.lock.Unlock()
 
-	hash := hex.EncodeToString(types.HashTransaction(tx))
-	pool.txx[hash] = tx
+	hash := types.HashTransaction(tx)
+	pool.txx[hex.EncodeToString(hash)] = tx
+	if pool.events != nil {
+		pool.events.Publish(&proto.Event{
+			Topic:       TopicTx,
+			Hash:        hash,
+			Transaction: tx,
+		})
+	}
 	return true // In this case if we don't have it, we are going to Add() it. These bools allows use a skip in checks in HandleTransaction()
 }
 
+// ProtocolVersion is the version of the PeerService we speak, we still talk to nodes
+// down to MinProtocolVersion. Bump it when peers need new code to understand us.
+const (
+	ProtocolVersion    = 2 // 2: peers talk over one Connect stream.
+	MinProtocolVersion = 2
+)
+
 type ServerConfig struct {
-	Version    string
-	ListenAddr string
-	PrivateKey *crypto.PrivateKey // Validator key
+	Version          string
+	ListenAddr       string             // The PeerService, for other nodes.
+	AdvertiseAddr    string             // host:port other nodes can dial us at, when that's not ListenAddr. Behind NAT for example.
+	PublicListenAddr string             // The grpc PublicAPI for wallets and other clients, disabled when empty.
+	APIListenAddr    string             // The JSON API for wallets and other clients, disabled when empty.
+	AdminListenAddr  string             // The grpc AdminAPI for the operator, disabled when empty.
+	PrivateKey       *crypto.PrivateKey // Validator key
+	// NodeKey is who we are on the network, our nodeId is its public key. NewNode generates one when nil,
+	// then we are a new node every run. See LoadOrCreateNodeKey.
+	NodeKey *crypto.PrivateKey
+
+	// Every peer of the network needs the same PeerToken, clients of the PublicAPI and the JSON API need the
+	// PublicToken. Empty means no token needed. The rate limits are per remote ip.
+	PeerToken       string
+	PeerRateLimit   RateLimit
+	PublicToken     string
+	PublicRateLimit RateLimit
+	AdminToken      string
+
+	// TLS of the listeners, nil is plaintext. Peers do mutual TLS with certificates of the NodeKey, see tls.go.
+	PeerTLS   *TLSConfig
+	PublicTLS *TLSConfig
+	AdminTLS  *TLSConfig
+	APITLS    *TLSConfig
+	// Browsers only get to subscribe on the websocket of the JSON API from these origins ("*" is any) or its own.
+	APIOrigins []string
+
+	// Fast sync, if set the node starts from this snapshot instead of replaying from genesis.
+	SnapshotFile     string
+	TrustedBlockHash []byte // The hash of the last block of the snapshot we got from a source we trust, required with SnapshotFile.
+
+	// PruneBlocks is the number of recent blocks we keep the bodies of. 0 keeps everything.
+	PruneBlocks int
+	// IndexAddresses keeps the history of every address, for wallets and explorers.
+	IndexAddresses bool
+
+	// Genesis of the network we are part of, the dev network (DefaultGenesis) when nil.
+	Genesis *Genesis
+	// DataDir is where the node keeps the files it writes. The chain itself is in memory for now.
+	DataDir string
+	// LogLevel is debug, info, warn or error. Debug when empty.
+	LogLevel string
+
+	// Inbound peers dialed us, outbound peers we dialed. 0 means the default.
+	MaxInboundPeers  int
+	MaxOutboundPeers int
+	// We ping every peer each PingInterval and drop the ones that miss maxPingFailures pongs in a row.
+	PingInterval time.Duration
+	PingTimeout  time.Duration
+	// Peers get banned for BanDuration once their misbehaviour score reaches BanThreshold. 0 means the default.
+	BanThreshold int
+	BanDuration  time.Duration
 }
 
 type Node struct {
@@ -87,173 +184,505 @@ type Node struct {
 	logger       *zap.SugaredLogger
 
 	peerLock sync.RWMutex
-	peers    map[proto.NodeClient]*proto.Version
-	mempool  *Mempool
-
-	proto.UnimplementedNodeServer
+	peers    map[string]*remotePeer // hex nodeId => peer
+	// selfAddrs are the addresses we found out are us, guarded by the peerLock.
+	selfAddrs map[string]bool
+	nodeID    []byte      // The public key of the NodeKey.
+	peerTLS   *tls.Config // Both sides of our peer connections, nil without PeerTLS.
+	peerGuard *rpcGuard   // Also checks every message a peer sends us, nil until we start.
+	mempool   *Mempool
+	chain     *Chain
+	history   *MemoryHistoryIndex // nil unless IndexAddresses is set.
+	events    *EventBus
+	bans      *banList
+	addrs     *addrManager // The addresses of the network we know about.
+
+	// blockLock makes sure we add one block at a time.
+	blockLock sync.Mutex
+
+	inflightLock sync.Mutex
+	inflight     map[string]time.Time // hash => when we ask another peer for it, see request.
+
+	quit     chan struct{}
+	stopOnce sync.Once
+	stopLock sync.Mutex
+	stops    []func() // stops everything we serve, see Stop.
+
+	proto.UnimplementedPeerServiceServer
+	proto.UnimplementedPublicAPIServer
+	proto.UnimplementedAdminAPIServer
 }
 
 func NewNode(cfg ServerConfig) *Node {
+	if cfg.Genesis == nil {
+		cfg.Genesis = DefaultGenesis()
+	}
+	if cfg.MaxInboundPeers == 0 {
+		cfg.MaxInboundPeers = defaultMaxInboundPeers
+	}
+	if cfg.MaxOutboundPeers == 0 {
+		cfg.MaxOutboundPeers = defaultMaxOutboundPeers
+	}
+	if cfg.PingInterval == 0 {
+		cfg.PingInterval = defaultPingInterval
+	}
+	if cfg.PingTimeout == 0 {
+		cfg.PingTimeout = defaultPingTimeout
+	}
+	if cfg.BanThreshold == 0 {
+		cfg.BanThreshold = defaultBanThreshold
+	}
+	if cfg.BanDuration == 0 {
+		cfg.BanDuration = defaultBanDuration
+	}
+	if cfg.NodeKey == nil {
+		cfg.NodeKey = crypto.GeneratePrivateKey()
+	}
+	var banFile, addrFile string
+	if cfg.DataDir != "" {
+		banFile = filepath.Join(cfg.DataDir, banFileName)
+		addrFile = filepath.Join(cfg.DataDir, addrFileName)
+	}
 	loggerConfig := zap.NewDevelopmentConfig()
 	loggerConfig.EncoderConfig.TimeKey = ""
+	if level, err := zap.ParseAtomicLevel(cfg.LogLevel); err == nil && cfg.LogLevel != "" {
+		loggerConfig.Level = level
+	}
 	logger, _ := loggerConfig.Build()
-	return &Node{
-		peers:        make(map[proto.NodeClient]*proto.Version),
+	n := &Node{
+		peers:        make(map[string]*remotePeer),
+		selfAddrs:    make(map[string]bool),
+		nodeID:       cfg.NodeKey.Public().Bytes(),
+		inflight:     make(map[string]time.Time),
+		quit:         make(chan struct{}),
 		logger:       logger.Sugar(),
 		mempool:      NewMempool(),
+		events:       NewEventBus(),
+		bans:         newBanList(banFile, cfg.BanThreshold, cfg.BanDuration),
+		addrs:        newAddrManager(addrFile),
 		ServerConfig: cfg,
 	}
+	n.mempool.events = n.events
+	n.setChain(NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), cfg.Genesis))
+	return n
 }
 
-func (n *Node) addPeer(c proto.NodeClient, v *proto.Version) {
+func (n *Node) setChain(chain *Chain) {
+	chain.Notify(n.events)
+	n.chain = chain
+}
+
+func (n *Node) addPeer(p *remotePeer) error {
 	n.peerLock.Lock()
 	defer n.peerLock.Unlock()
 
 	// Handle the Logic where we decide to accept or drop
 	// the incoming node connection. it's simple logic to see if we have max connections or not.
-
-	n.peers[c] = v // Here we add the peer. It's basically accepted.
-
-	// Connect to all peers in the recieved list of peers
-	// loop through the list of peers, see if you have the version and connect with them.
-	if len(v.PeerList) > 0 {
-		go n.bootstrapNetwork(v.PeerList)
+	v := p.version
+	if old, ok := n.peers[p.id()]; ok {
+		if p.outbound || old.version.ListenAddr != v.ListenAddr {
+			// We already talk to this node, maybe under another address. "localhost:3000" and "127.0.0.1:3000" for example.
+			return status.Errorf(codes.AlreadyExists, "already connected to node %s as %s", p.id(), old.version.ListenAddr)
+		}
+		// The peer restarted and connected again, the old connection is dead.
+		old.close("replaced by a new connection")
+	} else if old := n.peerWithAddr(v.ListenAddr); old != nil {
+		// Restarted with a new node key? Anybody can claim an address, so only when the old connection stopped
+		// answering pings, or when we dialed the address ourselves and the new key answered.
+		if !p.outbound && old.failures == 0 {
+			return status.Errorf(codes.AlreadyExists, "already connected to node %s at %s", old.id(), v.ListenAddr)
+		}
+		delete(n.peers, old.id())
+		old.close("replaced by a new connection")
+	} else if max := n.maxPeers(p.outbound); n.countPeers(p.outbound) >= max {
+		return status.Errorf(codes.ResourceExhausted, "we are full, (%d) %s peers", max, p.direction())
 	}
 
+	n.peers[p.id()] = p // Here we add the peer. It's basically accepted.
+	n.events.Publish(&proto.Event{
+		Topic:  TopicPeerConnected,
+		Height: v.Height,
+		Peer:   v.ListenAddr,
+	})
+
 	// Print out here who are we as well [%s]. Our listen Address
 	n.logger.Debugw("new peer successfully connected",
 		"ourNode:", n.ListenAddr,
 		"remoteNode:", v.ListenAddr,
-		"height:", v.Height) // You'll see the address and nodes connected to each other due to PEER DISCOVERY.
+		"nodeId:", p.id(),
+		"height:", v.Height, // You'll see the address and nodes connected to each other due to PEER DISCOVERY.
+		"outbound:", p.outbound)
+	return nil
 }
 
-func (n *Node) deletePeer(c proto.NodeClient) {
-	n.peerLock.Lock()
-	defer n.peerLock.Unlock()
-	delete(n.peers, c)
-}
+func (n *Node) Start(listenAddr string, bootstrapNodes []string) error {
+	n.ListenAddr = listenAddr
 
-func (n *Node) bootstrapNetwork(addrs []string) error {
-	for _, addr := range addrs {
-		if !n.canConnectWith(addr) {
-			continue
-		}
-		if addr == n.ListenAddr {
-			continue
+	if n.DataDir != "" {
+		if err := os.MkdirAll(n.DataDir, 0700); err != nil {
+			return err
 		}
-		n.logger.Debugw("dialing remote node", "we", n.ListenAddr, "remoteNode", addr)
-		c, v, err := n.dialRemoteNode(addr)
+	}
+	if err := n.bans.load(); err != nil {
+		return err
+	}
+	if err := n.addrs.load(); err != nil {
+		return err
+	}
+	if n.AdvertiseAddr != "" {
+		addr, err := normalizeAddr(n.AdvertiseAddr)
 		if err != nil {
 			return err
 		}
-		// There are a couple of things we can do. Do not return the hanshake but each time we recieve a handshake we could call handshake back.
-		// But in this case, do this: because v is the version you get back from the guy. So we can add the connect 'c' with the version 'v'.
-		n.addPeer(c, v)
+		if host, _, _ := net.SplitHostPort(addr); !hasHost(host) {
+			return fmt.Errorf("advertise address %q has no host", n.AdvertiseAddr)
+		}
+		n.AdvertiseAddr = addr
+		n.addSelfAddr(addr)
+	}
+	if addr, err := canonicalAddr(listenAddr, ""); err == nil {
+		n.addSelfAddr(addr)
+	}
+	if n.SnapshotFile != "" {
+		if err := n.loadSnapshot(); err != nil {
+			return err
+		}
+	}
+	if n.IndexAddresses {
+		n.history = NewMemoryHistoryIndex()
+		if err := n.chain.AddIndexer(n.history); err != nil {
+			return err
+		}
+	}
+	if n.PruneBlocks > 0 {
+		if err := n.chain.EnablePruning(n.PruneBlocks); err != nil {
+			return err
+		}
 	}
-	return nil
-}
-
-func (n *Node) Start(listenAddr string, bootstrapNodes []string) error {
-	n.ListenAddr = listenAddr
 
-	var (
-		opts       = []grpc.ServerOption{}
-		grpcServer = grpc.NewServer(opts...)
-	)
+	guard := newRPCGuard(n.PeerToken, n.PeerRateLimit)
+	guard.isBanned = n.bans.isBanned
+	guard.onRateLimited = func(ip string) {
+		n.misbehaving(ip, scoreRateLimited, "flooding us")
+	}
+	n.peerGuard = guard
+	opts := guard.serverOptions()
+	if n.PeerTLS != nil {
+		tlsConfig, err := PeerTLSConfig(n.NodeKey, *n.PeerTLS)
+		if err != nil {
+			return fmt.Errorf("peer tls: %w", err)
+		}
+		n.peerTLS = tlsConfig
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	publicOpts, err := n.apiServerOptions(n.PublicTLS)
+	if err != nil {
+		return fmt.Errorf("public api tls: %w", err)
+	}
+	adminOpts, err := n.apiServerOptions(n.AdminTLS)
+	if err != nil {
+		return fmt.Errorf("admin api tls: %w", err)
+	}
+	apiTLS, err := n.apiTLSConfig(n.APITLS)
+	if err != nil {
+		return fmt.Errorf("json api tls: %w", err)
+	}
+	grpcServer := grpc.NewServer(opts...)
 	ln, err := net.Listen("tcp", listenAddr)
 	if err != nil {
 		return err
 	}
-	proto.RegisterNodeServer(grpcServer, n)
+	proto.RegisterPeerServiceServer(grpcServer, n)
+	n.onStop(grpcServer.Stop)
 	n.logger.Infow("node started...", "port", n.ListenAddr)
 
-	//  bootstrap the network with a list of already known nodes
-	// in the network.
-	if len(bootstrapNodes) > 0 {
-		go n.bootstrapNetwork(bootstrapNodes)
+	if n.PublicListenAddr != "" {
+		go func() {
+			if err := n.startPublicServer(publicOpts...); err != nil {
+				n.logger.Errorw("public api server error", "err", err)
+			}
+		}()
 	}
 
+	//  bootstrap the network with a list of already known nodes
+	// in the network. We keep reconnecting to them when they go away,
+	// the rest of our outbound peers we pick from the addresses we learn.
+	go n.connectLoop(bootstrapNodes)
+	go n.pingLoop()
+	n.onStop(n.saveAddrs)
+
 	if n.PrivateKey != nil {
 		go n.validatorLoop()
 	}
 
-	return grpcServer.Serve(ln)
-}
-
-func (n *Node) Handshake(ctx context.Context, v *proto.Version) (*proto.Version, error) {
-	c, err := makeNodeClient(v.ListenAddr) // We need to have the remote addr of the node that is calling.
-	if err != nil {
-		return nil, err
+	if n.AdminListenAddr != "" {
+		go func() {
+			if err := n.startAdminServer(adminOpts...); err != nil {
+				n.logger.Errorw("admin api server error", "err", err)
+			}
+		}()
 	}
 
-	n.addPeer(c, v)
+	if n.APIListenAddr != "" {
+		go func() {
+			if err := n.startAPIServer(apiTLS); err != nil {
+				n.logger.Errorw("api server error", "err", err)
+			}
+		}()
+	}
 
-	return n.getVersion(), nil // In this handshake it's going to accept the connection yes or no. Because we are going to maintain a map of grpc stuff.
+	if err := grpcServer.Serve(ln); err != grpc.ErrServerStopped {
+		return err
+	}
+	return nil
 }
 
 func (n *Node) HandleTransaction(ctx context.Context, tx *proto.Transaction) (*proto.Ack, error) {
-	peer, _ := peer.FromContext(ctx)
+	if err := n.receiveTransaction(remoteIP(ctx), tx); err != nil {
+		return nil, err
+	}
+	return &proto.Ack{}, nil
+}
+
+// receiveTransaction adds a tx a peer at from sent us to the mempool and announces it.
+func (n *Node) receiveTransaction(from string, tx *proto.Transaction) error {
 	hash := hex.EncodeToString(types.HashTransaction(tx))
 
-	// Wrap with this if statement to fix infinite loop, before we actually broadcast we are going to check .
-	if n.mempool.Add(tx) { // If we added the mempool then we are going to broadcast it.
-		n.logger.Debugw("received tx", "from", peer.Addr, "hash", hash, "we", n.ListenAddr)
-		go func() { // because if we go go boradcast() we will never see the error. Here we handle the error.
-			if err := n.broadcast(tx); err != nil {
-				n.logger.Errorw("broadcast error", "err", err)
-			}
-		}()
+	if n.mempool.Has(tx) {
+		return nil
+	}
+	if _, _, err := n.chain.GetTransaction(types.HashTransaction(tx)); err == nil {
+		return nil // The peer didn't see the block yet, that's not its fault.
+	}
+	if err := n.chain.ValidateTransaction(tx); err != nil {
+		// The output may be spent by a block the peer hasn't seen yet, or created by one we haven't.
+		// Honest peers relay those during every double spend race, we only hold a broken tx against them.
+		if errors.Is(err, errUnknownInput) {
+			return status.Errorf(codes.FailedPrecondition, "invalid tx: %s", err)
+		}
+		n.misbehaving(from, scoreInvalidTx, "invalid tx")
+		return status.Errorf(codes.InvalidArgument, "invalid tx: %s", err)
 	}
 
+	// Wrap with this if statement to fix infinite loop, before we actually announce we are going to check .
+	if n.mempool.Add(tx) { // If we added the mempool then we are going to announce it.
+		n.logger.Debugw("received tx", "from", from, "hash", hash, "we", n.ListenAddr)
+		n.announce(tx)
+	}
+	return nil
+}
+
+func (n *Node) HandleBlock(ctx context.Context, b *proto.Block) (*proto.Ack, error) {
+	if err := n.receiveBlock(remoteIP(ctx), b); err != nil {
+		return nil, err
+	}
 	return &proto.Ack{}, nil
 }
 
+// receiveBlock adds a block a peer at from sent us to our chain and announces it.
+func (n *Node) receiveBlock(from string, b *proto.Block) error {
+	if b.Header == nil {
+		n.misbehaving(from, scoreInvalidBlock, "invalid block")
+		return status.Error(codes.InvalidArgument, "invalid block: no header")
+	}
+	hash := types.HashBlock(b)
+
+	n.blockLock.Lock()
+	defer n.blockLock.Unlock()
+
+	if _, err := n.chain.GetBlockByHash(hash); err == nil {
+		return nil // We have it already, probably from another peer.
+	}
+	tip, err := n.chain.GetBlockByHeight(n.chain.Height())
+	if err != nil {
+		return err
+	}
+	// A block on top of something we don't know about could be fine, we could be the one behind.
+	if !bytes.Equal(b.Header.PrevHash, types.HashBlock(tip)) {
+		return status.Error(codes.FailedPrecondition, "block does not extend our chain")
+	}
+	if err := n.chain.AddBlock(b); err != nil {
+		n.misbehaving(from, scoreInvalidBlock, "invalid block")
+		return status.Errorf(codes.InvalidArgument, "invalid block: %s", err)
+	}
+	n.logger.Debugw("received block", "hash", hex.EncodeToString(hash), "height", n.chain.Height(), "we", n.ListenAddr)
+	n.announce(b)
+	return nil
+}
+
+// receiveVote checks a vote a peer at from sent us. Nothing counts votes yet, so a valid one goes no further.
+func (n *Node) receiveVote(from string, v *proto.Vote) error {
+	if err := types.VerifyVote(v); err != nil {
+		n.misbehaving(from, scoreInvalidVote, "invalid vote")
+		return err
+	}
+	if !n.chain.Genesis().IsValidator(v.PublicKey) {
+		n.misbehaving(from, scoreInvalidVote, "vote of a non validator")
+		return fmt.Errorf("vote of (%s) who is not a validator", hex.EncodeToString(v.PublicKey))
+	}
+	n.logger.Debugw("received vote", "from", from, "block", hex.EncodeToString(v.BlockHash), "height", v.Height, "we", n.ListenAddr)
+	return nil
+}
+
+func (n *Node) GetSnapshot(ctx context.Context, req *proto.SnapshotRequest) (*proto.Snapshot, error) {
+	// No block gets added halfway through, the headers, utxos and blocks are all of the same height.
+	n.blockLock.Lock()
+	defer n.blockLock.Unlock()
+	return n.chain.Snapshot(int(req.RecentBlocks))
+}
+
+func (n *Node) loadSnapshot() error {
+	if len(n.TrustedBlockHash) == 0 {
+		return fmt.Errorf("snapshot file %s needs a trusted block hash", n.SnapshotFile)
+	}
+	snap, err := ReadSnapshotFile(n.SnapshotFile)
+	if err != nil {
+		return err
+	}
+	chain, err := NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), n.Genesis, snap, n.TrustedBlockHash)
+	if err != nil {
+		return err
+	}
+	n.setChain(chain)
+	n.logger.Infow("loaded snapshot", "file", n.SnapshotFile, "height", chain.Height())
+	return nil
+}
+
 func (n *Node) validatorLoop() {
+	blockTime := n.Genesis.BlockTime()
 	n.logger.Infow("starting validator loop", "pubkey", n.PrivateKey.Public(), "blockTime", blockTime)
 	ticker := time.NewTicker(blockTime)
+	defer ticker.Stop()
 	for {
-		<-ticker.C
+		select {
+		case <-ticker.C:
+		case <-n.quit:
+			return
+		}
 
 		txx := n.mempool.Clear() // We are going to clear the mempool, with the transactions, and these transactions we are going to froge into a block.
 		n.logger.Debugw("time to create a new block", "lenTx", len(txx))
 	}
 }
 
-// The thing is because we don't have the concept of messages, we have the concept of proto types. So we need to say here if you want to broadcast something you pass in msg of any type.
-func (n *Node) broadcast(msg any) error {
-	for peer := range n.peers {
-		// So we are going to loop through all the peers in our connection map (where ever you are keeping these proto clients), and for each client we find, we are going to call the remote procedure and it is going to be the HandleTrasaction(). Which means it is going to boradcast it again and probably again to us.
-		switch v := msg.(type) {
-		case *proto.Transaction:
-			_, err := peer.HandleTransaction(context.Background(), v)
-			if err != nil {
-				return err
-			}
-		}
+// dialRemoteNode dials addr, which has to be canonical, and does the handshake over a new Connect stream.
+func (n *Node) dialRemoteNode(addr string) (*remotePeer, error) {
+
+	conn, err := n.dialPeer(addr)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	ctx, cancel := context.WithCancel(context.Background())
+	fail := func(err error) (*remotePeer, error) {
+		cancel()
+		conn.Close()
+		return nil, err
+	}
+	stream, err := proto.NewPeerServiceClient(conn).Connect(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	env, err := recvWithin(stream, handshakeTimeout)
+	if err != nil {
+		return fail(err)
+	}
+	challenge := env.GetChallenge()
+	if challenge == nil {
+		return fail(fmt.Errorf("%s: no challenge", addr))
+	}
+	n.peerLock.RLock()
+	old, ok := n.peers[nodeIDString(challenge.NodeId)]
+	n.peerLock.RUnlock()
+	if ok {
+		// Hang up before the remote node replaces its connection to us with this one.
+		return fail(status.Errorf(codes.AlreadyExists, "already connected to node %s as %s", old.id(), old.version.ListenAddr))
+	}
+	version := n.getVersion()
+	version.Challenge = util.RandomHash()[:challengeLen]
+	signVersion(n.NodeKey, challenge.Nonce, version)
+	if err := stream.Send(&proto.Envelope{Msg: &proto.Envelope_Version{Version: version}}); err != nil {
+		return fail(err)
+	}
+	if env, err = recvWithin(stream, handshakeTimeout); err != nil {
+		return fail(err)
+	}
+	v := env.GetVersion()
+	if v == nil {
+		return fail(fmt.Errorf("%s: no version", addr))
+	}
+	if err := verifyVersion(version.Challenge, v); err != nil {
+		return fail(fmt.Errorf("%s: %w", addr, err))
+	}
+	if remote, ok := peer.FromContext(stream.Context()); n.peerTLS != nil && (!ok || !bytes.Equal(certNodeID(remote.AuthInfo), v.NodeId)) {
+		return fail(fmt.Errorf("%s: tls certificate is not of the node", addr))
+	}
+	if bytes.Equal(v.NodeId, n.nodeID) {
+		n.addSelfAddr(addr)
+		return fail(fmt.Errorf("%s is ourselves", addr))
+	}
+	// The remote node checked us, now we check it. It could be older code that accepts anybody.
+	if err := n.checkVersion(v); err != nil {
+		return fail(err)
+	}
+	if v.ObservedIp != "" {
+		n.learnObservedIP(v.ObservedIp)
+	}
+	v.ListenAddr = addr // What it advertises might not even have a host, we know this one works.
+	p := newRemotePeer(stream, v, true)
+	p.conn = conn
+	p.cancel = cancel
+	return p, nil
 }
 
-func (n *Node) dialRemoteNode(addr string) (proto.NodeClient, *proto.Version, error) {
-
-	c, err := makeNodeClient(addr)
+// connect dials addr and adds it as an outbound peer.
+func (n *Node) connect(addr string) error {
+	addr, err := canonicalAddr(addr, "")
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
-	v, err := c.Handshake(context.Background(), n.getVersion())
+	n.logger.Debugw("dialing remote node", "we", n.ListenAddr, "remoteNode", addr)
+	n.addrs.attempt(addr)
+	p, err := n.dialRemoteNode(addr)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
-	return c, v, nil
+	if err := n.addPeer(p); err != nil {
+		p.release()
+		return err
+	}
+	go n.runPeer(p)
+	n.addrs.good(addr)
+	go n.requestAddrs(p)
+	return nil
 }
 
 func (n *Node) getVersion() *proto.Version { // You are going to call version on this node, it basically means its going to return it's proto.Version
 	return &proto.Version{
-		Version:    "blocker-0.1",
-		Height:     0,
-		ListenAddr: n.ListenAddr,
-		PeerList:   n.getPeerList(),
+		Version:         n.Version,
+		Height:          int32(n.chain.Height()),
+		ListenAddr:      n.advertisedAddr(),
+		NodeId:          n.nodeID,
+		Pruned:          n.chain.IsPruned(),
+		LowestHeight:    int32(n.chain.LowestHeight()),
+		ChainId:         n.Genesis.ChainID,
+		GenesisHash:     n.chain.GenesisHash(),
+		ProtocolVersion: ProtocolVersion,
+	}
+}
+
+// checkVersion makes sure a remote node is on our network and speaks our protocol.
+// Test and staging networks run side by side, they must never connect to each other.
+func (n *Node) checkVersion(v *proto.Version) error {
+	if v.ChainId != n.Genesis.ChainID {
+		return fmt.Errorf("remote node is on chain (%s), we are on chain (%s)", v.ChainId, n.Genesis.ChainID)
+	}
+	if !bytes.Equal(v.GenesisHash, n.chain.GenesisHash()) {
+		return fmt.Errorf("remote node has genesis block (%s), ours is (%s)", hex.EncodeToString(v.GenesisHash), hex.EncodeToString(n.chain.GenesisHash()))
+	}
+	if v.ProtocolVersion < MinProtocolVersion || v.ProtocolVersion > ProtocolVersion {
+		return fmt.Errorf("remote node speaks protocol version (%d), we speak (%d) to (%d)", v.ProtocolVersion, MinProtocolVersion, ProtocolVersion)
 	}
+	return nil
 }
 
 func (n *Node) getPeerList() []string { // returns a slice of strings because our peerlist is a map above
@@ -261,34 +690,36 @@ func (n *Node) getPeerList() []string { // returns a slice of strings because ou
 	defer n.peerLock.RUnlock()
 
 	peers := []string{}
-	for _, version := range n.peers {
-		peers = append(peers, version.ListenAddr)
+	for _, p := range n.peers {
+		peers = append(peers, p.version.ListenAddr)
 	}
 	return peers
 }
 
 func (n *Node) canConnectWith(addr string) bool {
 	// We are going to check if we can connect to a certain address. We are going to check is this address the same as ours? Then we don't connect. Then we are going to loop through all of our connected peers and check if the address we are trying to connect to is already in that list. If its already in that list we don't connect. If it is not in that list we can connect.
-	if n.ListenAddr == addr {
+	addr, err := canonicalAddr(addr, "")
+	if err != nil || n.bans.isBanned(addr) {
 		return false
 	}
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
 
-	connectedPeers := n.getPeerList()
-
-	for _, connectedAddr := range connectedPeers {
-		if addr == connectedAddr {
-			return false
-		}
+	if n.selfAddrs[addr] || n.countPeers(true) >= n.MaxOutboundPeers {
+		return false
 	}
-	return true
+	return n.peerWithAddr(addr) == nil
 }
 
-func makeNodeClient(listenAddr string) (proto.NodeClient, error) {
-	c, err := grpc.Dial(listenAddr, grpc.WithInsecure()) // grpc.WI.. Fixes rpc error: code = Unknown desc = grpc: no transport security set.
-	if err != nil {
-		return nil, err
+func (n *Node) dialPeer(listenAddr string) (*grpc.ClientConn, error) {
+	opts := []grpc.DialOption{grpc.WithInsecure()} // grpc.WI.. Fixes rpc error: code = Unknown desc = grpc: no transport security set.
+	if n.peerTLS != nil {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(n.peerTLS))}
+	}
+	if n.PeerToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials(n.PeerToken)))
 	}
-	return proto.NewNodeClient(c), nil
+	return grpc.Dial(listenAddr, opts...)
 }
 
 // NOTE: Mutexes are slow. So how far you can go without using them.
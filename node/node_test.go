@@ -0,0 +1,281 @@
+package node
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	"github.com/Fito305/blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetTransactionStatus(t *testing.T) {
+	var (
+		n   = NewNode(ServerConfig{ListenAddr: ":3000"})
+		tx  = &proto.Transaction{Version: 42}
+		req = &proto.GetTransactionRequest{Hash: types.HashTransaction(tx)}
+	)
+	status, err := n.GetTransaction(context.Background(), req)
+	require.Nil(t, err)
+	assert.Equal(t, proto.TxStatus_TX_UNKNOWN, status.Status)
+
+	n.mempool.Add(tx)
+	status, err = n.GetTransaction(context.Background(), req)
+	require.Nil(t, err)
+	assert.Equal(t, proto.TxStatus_TX_PENDING, status.Status)
+
+	genesis, err := n.chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	req.Hash = types.HashTransaction(genesis.Transactions[0])
+	status, err = n.GetTransaction(context.Background(), req)
+	require.Nil(t, err)
+	assert.Equal(t, proto.TxStatus_TX_CONFIRMED, status.Status)
+	assert.Equal(t, int32(1), status.Confirmations)
+	assert.Equal(t, types.HashBlock(genesis), status.BlockHash)
+}
+
+func TestHandshakeChecksNetwork(t *testing.T) {
+	var (
+		n   = NewNode(ServerConfig{ListenAddr: "127.0.0.1:3000"})
+		ctx = context.Background()
+	)
+	v, err := handshake(t, ctx, n, crypto.GeneratePrivateKey(), "127.0.0.1:4000")
+	require.Nil(t, err)
+	assert.Equal(t, "blocker-dev", v.ChainId)
+	assert.Equal(t, []string{"127.0.0.1:4000"}, n.getPeerList())
+
+	for _, tamper := range []func(v *proto.Version){
+		func(v *proto.Version) { v.ChainId = "blocker-staging" },
+		func(v *proto.Version) { v.GenesisHash = util.RandomHash() },
+		func(v *proto.Version) { v.ProtocolVersion = ProtocolVersion + 1 },
+		func(v *proto.Version) { v.ProtocolVersion = MinProtocolVersion - 1 },
+	} {
+		_, err := handshake(t, ctx, n, crypto.GeneratePrivateKey(), "127.0.0.1:5000", tamper)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	}
+	assert.Equal(t, []string{"127.0.0.1:4000"}, n.getPeerList())
+}
+
+func TestNodesOnOtherNetworksDontConnect(t *testing.T) {
+	staging := DefaultGenesis()
+	staging.ChainID = "blocker-staging"
+	var (
+		addrA = freeAddr(t)
+		addrB = freeAddr(t)
+		addrC = freeAddr(t)
+		a     = NewNode(ServerConfig{})
+		b     = NewNode(ServerConfig{Genesis: staging})
+		c     = NewNode(ServerConfig{})
+	)
+	go a.Start(addrA, nil)
+	waitForListener(t, addrA)
+	go b.Start(addrB, []string{addrA})
+	waitForListener(t, addrB)
+	go c.Start(addrC, []string{addrB, addrA}) // b doesn't stop us from connecting to a.
+
+	require.Eventually(t, func() bool {
+		return len(a.getPeerList()) == 1 && len(c.getPeerList()) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{addrC}, a.getPeerList())
+	assert.Equal(t, []string{addrA}, c.getPeerList())
+	assert.Empty(t, b.getPeerList())
+}
+
+func TestHandshakeRejectsPeersOverTheInboundLimit(t *testing.T) {
+	var (
+		n   = NewNode(ServerConfig{ListenAddr: "127.0.0.1:3000", MaxInboundPeers: 1})
+		ctx = context.Background()
+	)
+	key := crypto.GeneratePrivateKey()
+	_, err := handshake(t, ctx, n, key, "127.0.0.1:4000")
+	require.Nil(t, err)
+
+	_, err = handshake(t, ctx, n, crypto.GeneratePrivateKey(), "127.0.0.1:5000")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// The same peer connecting again replaces its old connection.
+	_, err = handshake(t, ctx, n, key, "127.0.0.1:4000")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"127.0.0.1:4000"}, n.getPeerList())
+
+	// Another node key at its address only replaces it once the old connection stopped answering pings.
+	newKey := crypto.GeneratePrivateKey()
+	_, err = handshake(t, ctx, n, newKey, "127.0.0.1:4000")
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	n.peerLock.Lock()
+	n.peerWithAddr("127.0.0.1:4000").failures++
+	n.peerLock.Unlock()
+	_, err = handshake(t, ctx, n, newKey, "127.0.0.1:4000")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"127.0.0.1:4000"}, n.getPeerList())
+	n.peerLock.RLock()
+	assert.Equal(t, nodeIDString(newKey.Public().Bytes()), n.peerWithAddr("127.0.0.1:4000").id())
+	n.peerLock.RUnlock()
+}
+
+func TestDeadPeersAreDroppedAndBootstrapNodesReconnected(t *testing.T) {
+	var (
+		addrA = freeAddr(t)
+		addrB = freeAddr(t)
+		cfg   = ServerConfig{PingInterval: 50 * time.Millisecond, PingTimeout: 50 * time.Millisecond}
+		a     = NewNode(cfg)
+		b     = NewNode(cfg)
+	)
+	defer b.Stop()
+	sub := b.events.Subscribe(NewEventFilter([]string{TopicPeerConnected, TopicPeerDisconnected}, nil))
+	defer b.events.Unsubscribe(sub)
+	events := sub.C
+
+	go a.Start(addrA, nil)
+	waitForListener(t, addrA)
+	go b.Start(addrB, []string{addrA})
+	assert.Equal(t, addrA, nextEvent(t, events, TopicPeerConnected).Peer)
+
+	a.Stop()
+	assert.Equal(t, addrA, nextEvent(t, events, TopicPeerDisconnected).Peer)
+	assert.Empty(t, b.getPeerList())
+
+	a = NewNode(cfg)
+	defer a.Stop()
+	go a.Start(addrA, nil)
+	assert.Equal(t, addrA, nextEvent(t, events, TopicPeerConnected).Peer)
+	assert.Equal(t, []string{addrA}, b.getPeerList())
+	assert.Eventually(t, func() bool {
+		return len(a.getPeerList()) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func nextEvent(t *testing.T, events <-chan *proto.Event, topic string) *proto.Event {
+	select {
+	case e := <-events:
+		require.Equal(t, topic, e.Topic)
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s event", topic)
+		return nil
+	}
+}
+
+// pipeStream is one side of an in-memory Connect. Its ctx is what the node sees as the context of the call.
+type pipeStream struct {
+	ctx context.Context
+	in  chan *proto.Envelope
+	out chan *proto.Envelope
+}
+
+// newPipe returns both sides of a Connect, nothing is buffered.
+func newPipe(ctx context.Context) (*pipeStream, *pipeStream) {
+	a, b := make(chan *proto.Envelope), make(chan *proto.Envelope)
+	return &pipeStream{ctx: ctx, in: a, out: b}, &pipeStream{ctx: ctx, in: b, out: a}
+}
+
+func (s *pipeStream) Send(env *proto.Envelope) error {
+	select {
+	case s.out <- env:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func (s *pipeStream) Recv() (*proto.Envelope, error) {
+	select {
+	case env := <-s.in:
+		return env, nil
+	case <-s.ctx.Done():
+		return nil, io.EOF
+	}
+}
+
+func (s *pipeStream) Context() context.Context {
+	return s.ctx
+}
+
+// connectPipe opens a Connect to n over a pipe and answers its challenge with the version of sign. It returns
+// the version n answered with and our side of the pipe, the pipe is closed at the end of the test.
+func connectPipe(t *testing.T, ctx context.Context, n *Node, sign func(nonce []byte) *proto.Version) (*proto.Version, *pipeStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	ours, theirs := newPipe(ctx)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- n.servePeer(theirs)
+	}()
+
+	env, err := ours.Recv()
+	require.Nil(t, err)
+	require.NotNil(t, env.GetChallenge())
+	require.Nil(t, ours.Send(&proto.Envelope{Msg: &proto.Envelope_Version{Version: sign(env.GetChallenge().Nonce)}}))
+	select {
+	case env := <-ours.in:
+		return env.GetVersion(), ours, nil
+	case err := <-errc:
+		return nil, nil, err
+	case <-time.After(5 * time.Second):
+		t.Fatal("no answer to the handshake")
+		return nil, nil, nil
+	}
+}
+
+// remoteVersion signs the version of another node of our network with the node key key, listening at addr.
+// The changes are made before it gets signed.
+func remoteVersion(n *Node, key *crypto.PrivateKey, addr string, changes ...func(v *proto.Version)) func(nonce []byte) *proto.Version {
+	return func(nonce []byte) *proto.Version {
+		v := n.getVersion()
+		v.ListenAddr = addr
+		v.NodeId = key.Public().Bytes()
+		v.Challenge = util.RandomHash()[:challengeLen]
+		for _, change := range changes {
+			change(v)
+		}
+		signVersion(key, nonce, v)
+		return v
+	}
+}
+
+// handshake connects to n as remoteVersion.
+func handshake(t *testing.T, ctx context.Context, n *Node, key *crypto.PrivateKey, addr string, changes ...func(v *proto.Version)) (*proto.Version, error) {
+	v, _, err := connectPipe(t, ctx, n, remoteVersion(n, key, addr, changes...))
+	return v, err
+}
+
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func waitForListener(t *testing.T, addr string) {
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestStartReturnsWhenStoppedEarly(t *testing.T) {
+	n := NewNode(ServerConfig{})
+	n.Stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- n.Start(freeAddr(t), nil)
+	}()
+	select {
+	case err := <-errc:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Start didn't return")
+	}
+}
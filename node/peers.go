@@ -0,0 +1,338 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Fito305/blocker/proto"
+	"google.golang.org/grpc"
+)
+
+const (
+	defaultMaxInboundPeers  = 32
+	defaultMaxOutboundPeers = 8
+	defaultPingInterval     = 10 * time.Second
+	defaultPingTimeout      = 5 * time.Second
+	handshakeTimeout        = 10 * time.Second
+
+	maxPingFailures = 3
+
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = time.Minute
+)
+
+// remotePeer is a node we are connected to. Inbound peers dialed us, we dialed the outbound ones.
+type remotePeer struct {
+	stream   peerStream
+	conn     *grpc.ClientConn   // Outbound peers only.
+	cancel   context.CancelFunc // Ends the stream of an outbound peer.
+	version  *proto.Version
+	outbound bool
+	failures int // pings missed in a row, guarded by Node.peerLock.
+
+	out      chan *proto.Envelope // What the sendLoop sends next, see stream.go.
+	callLock sync.Mutex
+	nextID   uint64
+	calls    map[uint64]chan *proto.Envelope // request id => who waits for the answer
+
+	invLock sync.Mutex
+	known   invSet           // The hashes the peer has, see gossip.go.
+	pending []*proto.InvItem // What we announce with the next Inv, at most maxSendQueue.
+
+	closeOnce sync.Once
+	quit      chan struct{} // Closed when we disconnect, stops the loops of the peer.
+	reason    string        // Why we disconnect, set before quit is closed.
+	done      chan struct{} // Closed once runPeer closed the stream.
+}
+
+func newRemotePeer(stream peerStream, v *proto.Version, outbound bool) *remotePeer {
+	return &remotePeer{
+		stream:   stream,
+		version:  v,
+		outbound: outbound,
+		out:      make(chan *proto.Envelope, maxOutQueue),
+		calls:    make(map[uint64]chan *proto.Envelope),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// close disconnects p, runPeer tells it why and closes the stream.
+func (p *remotePeer) close(reason string) {
+	p.closeOnce.Do(func() {
+		p.reason = reason
+		close(p.quit)
+	})
+}
+
+// release ends the stream and closes the connection of an outbound peer. An inbound stream ends when
+// servePeer returns.
+func (p *remotePeer) release() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	if p.conn != nil {
+		p.conn.Close()
+	}
+}
+
+// id is the hex nodeId of the peer, it proved it owns the key in the handshake.
+func (p *remotePeer) id() string {
+	return nodeIDString(p.version.NodeId)
+}
+
+// banAddr is the address we score and ban p by. An inbound peer can claim any ListenAddr, so it's the ip it
+// connected from. We dialed an outbound peer at its ListenAddr ourselves.
+func (p *remotePeer) banAddr() string {
+	if !p.outbound {
+		if ip := remoteIP(p.stream.Context()); ip != "" {
+			return ip
+		}
+	}
+	return p.version.ListenAddr
+}
+
+func (p *remotePeer) direction() string {
+	if p.outbound {
+		return "outbound"
+	}
+	return "inbound"
+}
+
+// countPeers needs the peerLock.
+func (n *Node) countPeers(outbound bool) int {
+	count := 0
+	for _, p := range n.peers {
+		if p.outbound == outbound {
+			count++
+		}
+	}
+	return count
+}
+
+// peerWithAddr needs the peerLock.
+func (n *Node) peerWithAddr(addr string) *remotePeer {
+	for _, p := range n.peers {
+		if p.version.ListenAddr == addr {
+			return p
+		}
+	}
+	return nil
+}
+
+func (n *Node) maxPeers(outbound bool) int {
+	if outbound {
+		return n.MaxOutboundPeers
+	}
+	return n.MaxInboundPeers
+}
+
+// peerList returns the peers we are connected to, so we can call them without holding the peerLock.
+func (n *Node) peerList() []*remotePeer {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+
+	peers := make([]*remotePeer, 0, len(n.peers))
+	for _, p := range n.peers {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+// removePeer closes the connection to p. It does nothing when p was already replaced by a new connection.
+func (n *Node) removePeer(p *remotePeer, reason string) {
+	addr := p.version.ListenAddr
+
+	n.peerLock.Lock()
+	if n.peers[p.id()] != p {
+		n.peerLock.Unlock()
+		return
+	}
+	delete(n.peers, p.id())
+	height := p.version.Height
+	n.peerLock.Unlock()
+
+	p.close(reason)
+	n.logger.Infow("peer disconnected", "we", n.ListenAddr, "remoteNode", addr, "reason", reason)
+	n.events.Publish(&proto.Event{
+		Topic:  TopicPeerDisconnected,
+		Height: height,
+		Peer:   addr,
+	})
+}
+
+// pingLoop drops the peers that stopped answering. Without it we would keep broadcasting to them forever.
+func (n *Node) pingLoop() {
+	ticker := time.NewTicker(n.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-n.quit:
+			return
+		}
+		for _, p := range n.peerList() {
+			go n.pingPeer(p)
+		}
+	}
+}
+
+func (n *Node) pingPeer(p *remotePeer) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.PingTimeout)
+	defer cancel()
+
+	env, err := p.call(ctx, &proto.Envelope{Msg: &proto.Envelope_Ping{Ping: &proto.PingRequest{Height: int32(n.chain.Height())}}})
+	pong := env.GetPong()
+	if err == nil && pong == nil {
+		err = fmt.Errorf("answered ping with %T", env.Msg)
+	}
+
+	n.peerLock.Lock()
+	if err == nil {
+		p.failures = 0
+		p.version.Height = pong.Height
+		n.peerLock.Unlock()
+		return
+	}
+	p.failures++
+	failures := p.failures
+	n.peerLock.Unlock()
+
+	n.logger.Debugw("ping failed", "we", n.ListenAddr, "remoteNode", p.version.ListenAddr, "failures", failures, "err", err)
+	if failures >= maxPingFailures {
+		n.removePeer(p, "not answering pings")
+	}
+}
+
+// connectLoop keeps our outbound slots filled. The bootstrap nodes come first, when one goes away we
+// try again, waiting twice as long after each failed attempt. The other slots get addresses from n.addrs.
+func (n *Node) connectLoop(bootstrapNodes []string) {
+	var (
+		backoff  = make(map[string]time.Duration)
+		next     = make(map[string]time.Time)
+		ticker   = time.NewTicker(minReconnectBackoff)
+		lastSave = time.Now()
+	)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		for _, addr := range bootstrapNodes {
+			if !n.canConnectWith(addr) || now.Before(next[addr]) {
+				continue
+			}
+			if err := n.connect(addr); err != nil {
+				backoff[addr] = min(max(backoff[addr]*2, minReconnectBackoff), maxReconnectBackoff)
+				next[addr] = now.Add(backoff[addr])
+				n.logger.Debugw("could not connect to bootstrap node", "we", n.ListenAddr, "remoteNode", addr, "retryIn", backoff[addr], "err", err)
+				continue
+			}
+			delete(backoff, addr)
+			delete(next, addr)
+		}
+		n.fillOutbound()
+
+		if now.Sub(lastSave) >= addrSaveInterval {
+			n.saveAddrs()
+			lastSave = now
+		}
+
+		select {
+		case <-ticker.C:
+		case <-n.quit:
+			return
+		}
+	}
+}
+
+// fillOutbound dials addresses from n.addrs until our outbound slots are full or we run out of addresses.
+func (n *Node) fillOutbound() {
+	for {
+		n.peerLock.RLock()
+		free := n.MaxOutboundPeers - n.countPeers(true)
+		n.peerLock.RUnlock()
+		if free <= 0 {
+			return
+		}
+		addr, ok := n.addrs.pick(func(addr string) bool {
+			return !n.canConnectWith(addr)
+		})
+		if !ok {
+			return
+		}
+		if err := n.connect(addr); err != nil {
+			n.logger.Debugw("could not connect to remote node", "we", n.ListenAddr, "remoteNode", addr, "err", err)
+		}
+	}
+}
+
+// requestAddrs asks a peer we just connected to which addresses it knows.
+func (n *Node) requestAddrs(p *remotePeer) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.PingTimeout)
+	defer cancel()
+
+	env, err := p.call(ctx, &proto.Envelope{Msg: &proto.Envelope_GetAddrs{GetAddrs: &proto.GetAddrsRequest{}}})
+	resp := env.GetAddrList()
+	if err == nil && resp == nil {
+		err = fmt.Errorf("answered getAddrs with %T", env.Msg)
+	}
+	if err != nil {
+		n.logger.Debugw("could not get addresses", "we", n.ListenAddr, "remoteNode", p.version.ListenAddr, "err", err)
+		return
+	}
+	added := n.addrs.add(resp.Addrs, p.banAddr())
+	n.logger.Debugw("received addresses", "we", n.ListenAddr, "remoteNode", p.version.ListenAddr, "count", len(resp.Addrs), "new", added)
+}
+
+func (n *Node) saveAddrs() {
+	if err := n.addrs.save(); err != nil {
+		n.logger.Errorw("could not save addresses", "err", err)
+	}
+}
+
+func (n *Node) onStop(fn func()) {
+	n.stopLock.Lock()
+	select {
+	case <-n.quit:
+		// Stop beat Start to it, so fn runs right away.
+		n.stopLock.Unlock()
+		fn()
+	default:
+		n.stops = append(n.stops, fn)
+		n.stopLock.Unlock()
+	}
+}
+
+// Stop stops the servers and the loops of the node and closes the connections to its peers.
+// Start returns once the node stopped.
+func (n *Node) Stop() {
+	n.stopOnce.Do(func() {
+		close(n.quit)
+
+		// Our peers get a moment to hear why we go away, before the server does.
+		peers := n.peerList()
+		for _, p := range peers {
+			p.close("shutting down")
+		}
+		deadline := time.After(closeTimeout)
+		for _, p := range peers {
+			select {
+			case <-p.done:
+			case <-deadline:
+			}
+		}
+
+		n.stopLock.Lock()
+		stops := n.stops
+		n.stops = nil
+		n.stopLock.Unlock()
+		for _, stop := range stops {
+			stop()
+		}
+
+		n.peerLock.Lock()
+		n.peers = make(map[string]*remotePeer)
+		n.peerLock.Unlock()
+	})
+}
@@ -0,0 +1,216 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/Fito305/blocker/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The PublicAPI is the grpc service for wallets and other clients. It runs on its own
+// listener, so a flood of clients can't starve our peers and the other way around.
+
+func (n *Node) startPublicServer(opts ...grpc.ServerOption) error {
+	ln, err := net.Listen("tcp", n.PublicListenAddr)
+	if err != nil {
+		return err
+	}
+	n.logger.Infow("public api started...", "port", n.PublicListenAddr)
+	return n.newPublicServer(opts...).Serve(ln)
+}
+
+func (n *Node) newPublicServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(newRPCGuard(n.PublicToken, n.PublicRateLimit).serverOptions(), opts...)
+	server := grpc.NewServer(opts...)
+	proto.RegisterPublicAPIServer(server, n)
+	n.onStop(server.Stop)
+	return server
+}
+
+func (n *Node) SubmitTransaction(ctx context.Context, tx *proto.Transaction) (*proto.Ack, error) {
+	if err := n.submitTransaction(tx); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid tx: %s", err)
+	}
+	return &proto.Ack{}, nil
+}
+
+func (n *Node) GetTxProof(ctx context.Context, req *proto.TxProofRequest) (*proto.TxProof, error) {
+	return n.chain.GetTxProof(req.TxHash, req.BlockHash)
+}
+
+func (n *Node) GetTransaction(ctx context.Context, req *proto.GetTransactionRequest) (*proto.TransactionStatus, error) {
+	if tx, loc, err := n.chain.GetTransaction(req.Hash); err == nil {
+		blockHash, err := hex.DecodeString(loc.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+		return &proto.TransactionStatus{
+			Transaction:   tx,
+			Status:        proto.TxStatus_TX_CONFIRMED,
+			BlockHash:     blockHash,
+			Height:        int32(loc.Height),
+			Index:         int32(loc.Index),
+			Confirmations: int32(n.chain.Confirmations(loc.Height)),
+		}, nil
+	}
+	if tx, ok := n.mempool.Get(hex.EncodeToString(req.Hash)); ok {
+		return &proto.TransactionStatus{
+			Transaction: tx,
+			Status:      proto.TxStatus_TX_PENDING,
+		}, nil
+	}
+	return &proto.TransactionStatus{
+		Status: proto.TxStatus_TX_UNKNOWN,
+	}, nil
+}
+
+func (n *Node) GetAddressHistory(ctx context.Context, req *proto.AddressHistoryRequest) (*proto.AddressHistory, error) {
+	if n.history == nil {
+		return nil, fmt.Errorf("address index is not enabled on this node")
+	}
+	entries, total := n.history.GetHistory(req.Address, int(req.Offset), int(req.Limit))
+	history := &proto.AddressHistory{
+		Total: int32(total),
+	}
+	for _, entry := range entries {
+		txHash, err := hex.DecodeString(entry.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		asset, err := hex.DecodeString(entry.Asset)
+		if err != nil {
+			return nil, err
+		}
+		history.Entries = append(history.Entries, &proto.AddressHistoryEntry{
+			TxHash: txHash,
+			Height: int32(entry.Height),
+			Asset:  asset,
+			Amount: entry.Amount,
+		})
+	}
+	return history, nil
+}
+
+func (n *Node) GetBlock(ctx context.Context, req *proto.GetBlockRequest) (*proto.Block, error) {
+	var (
+		block *proto.Block
+		err   error
+	)
+	if len(req.Hash) > 0 {
+		block, err = n.chain.GetBlockByHash(req.Hash)
+	} else {
+		block, err = n.chain.GetBlockByHeight(int(req.Height))
+	}
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return block, nil
+}
+
+func (n *Node) GetAnchor(ctx context.Context, req *proto.AnchorRequest) (*proto.Anchor, error) {
+	anchor, err := n.chain.GetAnchor(req.ContentHash)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	resp := &proto.Anchor{
+		ContentHash:   req.ContentHash,
+		Height:        int32(anchor.Height),
+		OutIndex:      uint32(anchor.OutIndex),
+		Confirmations: int32(n.chain.Confirmations(anchor.Height)),
+	}
+	if resp.BlockHash, err = hex.DecodeString(anchor.BlockHash); err != nil {
+		return nil, err
+	}
+	if resp.TxHash, err = hex.DecodeString(anchor.TxHash); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (n *Node) GetBalance(ctx context.Context, req *proto.BalanceRequest) (*proto.Balance, error) {
+	balance, err := n.chain.GetBalance(req.Address)
+	if err != nil {
+		return nil, err
+	}
+	assets := make([]string, 0, len(balance))
+	for asset := range balance {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets) // NativeAsset is the empty string, so it always comes first.
+	resp := &proto.Balance{}
+	for _, asset := range assets {
+		id, err := hex.DecodeString(asset)
+		if err != nil {
+			return nil, err
+		}
+		resp.Assets = append(resp.Assets, &proto.AssetBalance{
+			Asset:  id,
+			Amount: balance[asset],
+		})
+	}
+	return resp, nil
+}
+
+func (n *Node) GetUTXOs(ctx context.Context, req *proto.UTXORequest) (*proto.UTXOList, error) {
+	utxos, err := n.chain.GetUTXOs(req.Address)
+	if err != nil {
+		return nil, err
+	}
+	resp := &proto.UTXOList{
+		Utxos: make([]*proto.UTXO, len(utxos)),
+	}
+	for i, utxo := range utxos {
+		if resp.Utxos[i], err = utxoToProto(utxo); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (n *Node) GetPeers(ctx context.Context, req *proto.PeersRequest) (*proto.PeerList, error) {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+
+	resp := &proto.PeerList{}
+	for _, p := range n.peers {
+		resp.Peers = append(resp.Peers, &proto.PeerInfo{
+			ListenAddr:   p.version.ListenAddr,
+			Version:      p.version.Version,
+			Height:       p.version.Height,
+			Outbound:     p.outbound,
+			Score:        int32(n.bans.score(p.banAddr())),
+			NodeId:       p.version.NodeId,
+			Pruned:       p.version.Pruned,
+			LowestHeight: p.version.LowestHeight,
+		})
+	}
+	sort.Slice(resp.Peers, func(i, j int) bool {
+		return resp.Peers[i].ListenAddr < resp.Peers[j].ListenAddr
+	})
+	return resp, nil
+}
+
+func (n *Node) Subscribe(req *proto.SubscribeRequest, stream proto.PublicAPI_SubscribeServer) error {
+	sub := n.events.Subscribe(NewEventFilter(req.Topics, req.Addresses))
+	defer n.events.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-sub.C:
+			if !ok {
+				return fmt.Errorf("subscription dropped, too slow to keep up with events")
+			}
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		}
+	}
+}
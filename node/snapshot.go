@@ -0,0 +1,276 @@
+package node
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	pb "github.com/golang/protobuf/proto"
+)
+
+// A snapshot file is the sha256 checksum of the snapshot followed by the protobuf encoded snapshot.
+
+// Snapshot returns the state of the chain at the current height with the last recentBlocks blocks.
+// We need at least the tip block, the chain that imports the snapshot validates the next block against it.
+func (c *Chain) Snapshot(recentBlocks int) (*proto.Snapshot, error) {
+	if recentBlocks < 1 {
+		return nil, fmt.Errorf("a snapshot needs at least 1 block, got (%d)", recentBlocks)
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	snap := &proto.Snapshot{
+		Height:  int32(c.headers.Height()),
+		Headers: make([]*proto.Header, c.headers.Len()),
+	}
+	for i := 0; i < c.headers.Len(); i++ {
+		snap.Headers[i] = c.headers.Get(i)
+	}
+
+	utxos, err := c.utxoStore.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, utxo := range utxos {
+		if utxo.Spent {
+			continue
+		}
+		entry, err := utxoToProto(utxo)
+		if err != nil {
+			return nil, err
+		}
+		snap.Utxos = append(snap.Utxos, entry)
+	}
+	sort.Slice(snap.Utxos, func(i, j int) bool {
+		if c := bytes.Compare(snap.Utxos[i].Hash, snap.Utxos[j].Hash); c != 0 {
+			return c < 0
+		}
+		return snap.Utxos[i].OutIndex < snap.Utxos[j].OutIndex
+	})
+
+	for height := c.headers.Height() - recentBlocks + 1; height <= c.headers.Height(); height++ {
+		if height < c.lowestHeight {
+			continue
+		}
+		b, err := c.blockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		snap.Blocks = append(snap.Blocks, b)
+	}
+
+	hashes := make([]string, 0, len(c.anchorProof))
+	for hash := range c.anchorProof {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		snap.Anchors = append(snap.Anchors, c.anchorProof[hash])
+	}
+	return snap, nil
+}
+
+// NewChainFromSnapshot creates a chain that continues from the height of the snapshot.
+// The snapshot is only accepted if its headers link up to our genesis, its utxo set matches the
+// state root of the last header, its anchors are proven under the headers and, when trustedHash is
+// given, the last header has that hash.
+func NewChainFromSnapshot(bs BlockStorer, txStore TXStorer, genesis *Genesis, snap *proto.Snapshot, trustedHash []byte) (*Chain, error) {
+	tip, err := SnapshotTip(snap)
+	if err != nil {
+		return nil, err
+	}
+	genesisBlock, err := genesis.Block()
+	if err != nil {
+		return nil, err
+	}
+	genesisHash := types.HashBlock(genesisBlock)
+	if !bytes.Equal(types.HashHeader(snap.Headers[0]), genesisHash) {
+		return nil, fmt.Errorf("snapshot is of another network, its genesis block is not ours")
+	}
+	if len(snap.Blocks) > len(snap.Headers) {
+		return nil, fmt.Errorf("snapshot has more blocks (%d) than headers (%d)", len(snap.Blocks), len(snap.Headers))
+	}
+	for i := 1; i < len(snap.Headers); i++ {
+		if !bytes.Equal(snap.Headers[i].GetPrevHash(), types.HashHeader(snap.Headers[i-1])) {
+			return nil, fmt.Errorf("snapshot header (%d) does not link to its previous header", i)
+		}
+	}
+	if len(trustedHash) > 0 && !bytes.Equal(types.HashHeader(tip), trustedHash) {
+		return nil, fmt.Errorf("snapshot block hash (%s) is not the trusted hash (%s)", hex.EncodeToString(types.HashHeader(tip)), hex.EncodeToString(trustedHash))
+	}
+
+	chain := &Chain{
+		blockStore:  bs,
+		txStore:     txStore,
+		utxoStore:   NewMemoryUTXOStore(),
+		utxoSet:     newUTXOSet(),
+		anchorStore: NewMemoryAnchorStore(),
+		anchorProof: make(map[string]*proto.SnapshotAnchor),
+		headers:     NewHeaderList(),
+		genesis:     genesis,
+		genesisHash: genesisHash,
+		undo:        make(map[int]*blockUndo),
+		// We don't have the blocks below the snapshot. Without undo data we can't roll them back either.
+		lowestHeight: int(snap.Height) + 1 - len(snap.Blocks),
+	}
+	for _, entry := range snap.Utxos {
+		utxo := utxoFromProto(entry)
+		if err := chain.utxoStore.Put(utxo); err != nil {
+			return nil, err
+		}
+		chain.utxoSet.add(utxo)
+	}
+	root, err := chain.StateRoot()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(root, tip.StateRoot) {
+		return nil, fmt.Errorf("snapshot utxo set does not match the state root of block (%d)", snap.Height)
+	}
+
+	for _, h := range snap.Headers {
+		chain.headers.Add(h)
+	}
+	for _, entry := range snap.Anchors {
+		anchor, err := anchorFromSnapshot(snap.Headers, entry)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := chain.anchorProof[anchor.ContentHash]; ok {
+			return nil, fmt.Errorf("snapshot has the anchor of (%s) twice", anchor.ContentHash)
+		}
+		if err := chain.anchorStore.Put(anchor); err != nil {
+			return nil, err
+		}
+		chain.anchorProof[anchor.ContentHash] = entry
+	}
+	for j, b := range snap.Blocks {
+		if b.Header == nil {
+			return nil, fmt.Errorf("invalid block in snapshot")
+		}
+		height := chain.lowestHeight + j // The blocks are the most recent ones, in order.
+		hash := types.HashBlock(b)
+		if !bytes.Equal(hash, types.HashHeader(chain.headers.Get(height))) || (len(b.Transactions) > 0 && !types.VerifyRootHash(b)) {
+			return nil, fmt.Errorf("snapshot block (%s) is not part of the snapshot chain", hex.EncodeToString(hash))
+		}
+		for i, tx := range b.Transactions {
+			if err := chain.txStore.Put(tx); err != nil {
+				return nil, err
+			}
+			loc := &TxLocation{
+				BlockHash: hex.EncodeToString(hash),
+				Height:    height,
+				Index:     i,
+			}
+			if err := chain.txStore.PutLocation(hex.EncodeToString(types.HashTransaction(tx)), loc); err != nil {
+				return nil, err
+			}
+		}
+		if err := chain.blockStore.Put(b); err != nil {
+			return nil, err
+		}
+	}
+	return chain, nil
+}
+
+// SnapshotTip returns the header of the last block of the snapshot, the one to trust the snapshot by.
+// It only checks the snapshot is complete enough to have one, not that it's valid.
+func SnapshotTip(snap *proto.Snapshot) (*proto.Header, error) {
+	if len(snap.Headers) == 0 || int(snap.Height) != len(snap.Headers)-1 {
+		return nil, fmt.Errorf("snapshot height (%d) does not match its headers (%d)", snap.Height, len(snap.Headers))
+	}
+	if len(snap.Blocks) == 0 {
+		return nil, fmt.Errorf("snapshot has no blocks, we need at least its tip block")
+	}
+	tip := snap.Headers[snap.Height]
+	if tip == nil {
+		return nil, fmt.Errorf("snapshot has no header at its height (%d)", snap.Height)
+	}
+	return tip, nil
+}
+
+// anchorFromSnapshot checks the tx of the anchor is in the block of the header at its height.
+func anchorFromSnapshot(headers []*proto.Header, entry *proto.SnapshotAnchor) (*Anchor, error) {
+	tx := entry.Transaction
+	if tx == nil || entry.Height < 0 || int(entry.Height) >= len(headers) ||
+		int(entry.OutIndex) >= len(tx.Outputs) || !types.IsDataOutput(tx.Outputs[entry.OutIndex]) {
+		return nil, fmt.Errorf("invalid anchor in snapshot")
+	}
+	header := headers[entry.Height]
+	txHash := types.HashTransaction(tx)
+	if !types.VerifyMerkleProof(header.RootHash, txHash, entry.Proof) {
+		return nil, fmt.Errorf("snapshot anchor in tx (%s) is not part of the snapshot chain", hex.EncodeToString(txHash))
+	}
+	return &Anchor{
+		ContentHash: hex.EncodeToString(types.HashData(tx.Outputs[entry.OutIndex].Data)),
+		BlockHash:   hex.EncodeToString(types.HashHeader(header)),
+		Height:      int(entry.Height),
+		TxHash:      hex.EncodeToString(txHash),
+		OutIndex:    int(entry.OutIndex),
+	}, nil
+}
+
+func WriteSnapshotFile(path string, snap *proto.Snapshot) error {
+	b, err := pb.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	checksum := sha256.Sum256(b)
+	return os.WriteFile(path, append(checksum[:], b...), 0644)
+}
+
+func ReadSnapshotFile(path string) (*proto.Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < sha256.Size {
+		return nil, fmt.Errorf("snapshot file %s is too short", path)
+	}
+	checksum := sha256.Sum256(b[sha256.Size:])
+	if !bytes.Equal(checksum[:], b[:sha256.Size]) {
+		return nil, fmt.Errorf("snapshot file %s has an invalid checksum", path)
+	}
+	snap := &proto.Snapshot{}
+	if err := pb.Unmarshal(b[sha256.Size:], snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func utxoToProto(utxo *UTXO) (*proto.UTXO, error) {
+	hash, err := hex.DecodeString(utxo.Hash)
+	if err != nil {
+		return nil, err
+	}
+	address, err := hex.DecodeString(utxo.Address)
+	if err != nil {
+		return nil, err
+	}
+	asset, err := hex.DecodeString(utxo.Asset)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.UTXO{
+		Hash:     hash,
+		OutIndex: uint32(utxo.OutIndex),
+		Amount:   utxo.Amount,
+		Address:  address,
+		Asset:    asset,
+	}, nil
+}
+
+func utxoFromProto(entry *proto.UTXO) *UTXO {
+	return &UTXO{
+		Hash:     hex.EncodeToString(entry.Hash),
+		OutIndex: int(entry.OutIndex),
+		Amount:   entry.Amount,
+		Address:  hex.EncodeToString(entry.Address),
+		Asset:    hex.EncodeToString(entry.Asset),
+	}
+}
@@ -0,0 +1,176 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	"github.com/Fito305/blocker/util"
+	pb "github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotFastSync(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	for i := 0; i < 20; i++ {
+		require.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	}
+	snap, err := chain.Snapshot(5)
+	require.Nil(t, err)
+	assert.Equal(t, 5, len(snap.Blocks))
+
+	file := filepath.Join(t.TempDir(), "snapshot")
+	require.Nil(t, WriteSnapshotFile(file, snap))
+	snap, err = ReadSnapshotFile(file)
+	require.Nil(t, err)
+
+	tip, err := chain.GetBlockByHeight(chain.Height())
+	require.Nil(t, err)
+	synced, err := NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis(), snap, types.HashBlock(tip))
+	require.Nil(t, err)
+	assert.Equal(t, chain.Height(), synced.Height())
+
+	balance, err := synced.GetBalance(devKey().Public().Address().Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, uint64(1000), balance[NativeAsset])
+
+	// We continue from the height of the snapshot.
+	require.Nil(t, synced.AddBlock(randomBlock(t, synced)))
+	assert.Equal(t, 21, synced.Height())
+
+	_, err = NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis(), snap, util.RandomHash())
+	assert.NotNil(t, err)
+
+	// Without the tip block we couldn't add the next one.
+	blocks := snap.Blocks
+	snap.Blocks = nil
+	_, err = NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis(), snap, types.HashBlock(tip))
+	assert.NotNil(t, err)
+	_, err = chain.Snapshot(0)
+	assert.NotNil(t, err)
+	snap.Blocks = blocks
+
+	snap.Utxos[0].Amount = 1000000
+	_, err = NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis(), snap, nil)
+	assert.NotNil(t, err)
+}
+
+func TestStartNeedsTheTrustedHashOfTheSnapshot(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	snap, err := chain.Snapshot(1)
+	require.Nil(t, err)
+	file := filepath.Join(t.TempDir(), "snapshot")
+	require.Nil(t, WriteSnapshotFile(file, snap))
+
+	n := NewNode(ServerConfig{SnapshotFile: file})
+	defer n.Stop()
+	assert.NotNil(t, n.Start(freeAddr(t), nil))
+}
+
+func TestSnapshotTip(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	require.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	snap, err := chain.Snapshot(1)
+	require.Nil(t, err)
+	tip, err := SnapshotTip(snap)
+	require.Nil(t, err)
+	assert.Equal(t, snap.Blocks[0].Header, tip)
+
+	// What a broken or hostile peer could send us.
+	for _, invalid := range []*proto.Snapshot{
+		{},
+		{Height: 1, Headers: snap.Headers[:1], Blocks: snap.Blocks},
+		{Height: 1, Headers: snap.Headers},
+		{Height: 1, Headers: []*proto.Header{snap.Headers[0], nil}, Blocks: snap.Blocks},
+	} {
+		_, err := SnapshotTip(invalid)
+		assert.NotNil(t, err)
+		_, err = NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis(), invalid, nil)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestReadSnapshotFileInvalidChecksum(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	snap, err := chain.Snapshot(1)
+	require.Nil(t, err)
+
+	file := filepath.Join(t.TempDir(), "snapshot")
+	require.Nil(t, WriteSnapshotFile(file, snap))
+	b, err := os.ReadFile(file)
+	require.Nil(t, err)
+	b[len(b)-1] ^= 0xff
+	require.Nil(t, os.WriteFile(file, b, 0644))
+
+	_, err = ReadSnapshotFile(file)
+	assert.NotNil(t, err)
+}
+
+func TestGetSnapshotWhileAddingBlocks(t *testing.T) {
+	n := NewNode(ServerConfig{ListenAddr: "127.0.0.1:3000"})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			if err := n.receiveBlock("", randomBlock(t, n.chain)); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		snap, err := n.GetSnapshot(context.Background(), &proto.SnapshotRequest{RecentBlocks: 2})
+		require.Nil(t, err)
+		_, err = NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis(), snap, nil)
+		require.Nil(t, err)
+	}
+}
+
+func TestSnapshotKeepsAnchors(t *testing.T) {
+	var (
+		chain   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+		privKey = devKey()
+		data    = util.RandomHash()
+	)
+	require.Nil(t, chain.EnablePruning(2))
+	tx := spendGenesisTx(t, chain, crypto.GeneratePrivateKey().Public().Address().Bytes(), 10)
+	tx.Outputs = append(tx.Outputs, &proto.TxOutput{Data: data})
+	tx.Inputs[0].Signature = types.SignTransaction(privKey, tx).Bytes()
+	block := randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, tx)
+	signBlock(t, chain, crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+	for i := 0; i < 5; i++ {
+		require.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	}
+	// The block of the anchor is long gone, the snapshot still has the anchor.
+	snap, err := chain.Snapshot(2)
+	require.Nil(t, err)
+	synced, err := NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis(), pb.Clone(snap).(*proto.Snapshot), nil)
+	require.Nil(t, err)
+	anchor, err := synced.GetAnchor(types.HashData(data))
+	require.Nil(t, err)
+	assert.Equal(t, 1, anchor.Height)
+	assert.Equal(t, hex.EncodeToString(types.HashBlock(block)), anchor.BlockHash)
+
+	// Nobody can slip in an anchor that isn't in the block of its header.
+	for _, entry := range snap.Anchors {
+		if entry.Height == 1 {
+			entry.Transaction = pb.Clone(entry.Transaction).(*proto.Transaction)
+			entry.Transaction.Outputs[2].Data = util.RandomHash()
+		}
+	}
+	_, err = NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis(), snap, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,170 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+)
+
+// The state root commits to the utxo set with a multiset hash (MuHash, bitcoin hashes its utxo set the same
+// way). Each unspent output maps to a number modulo the prime stateModulus, the set is the product of the
+// numbers of its outputs. Adding an output multiplies its number in, spending it divides it out again.
+// The order doesn't matter, so every node gets the same root out of the same set, and a block only costs
+// the outputs it touches instead of the whole set. The root is the sha256 of the product.
+// Spent outputs and data outputs are not part of the set.
+
+const stateElementLen = 384 // 3072 bits.
+
+// stateModulus is 2^3072 - 1103717, the largest 3072 bit prime.
+var stateModulus = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 8*stateElementLen), big.NewInt(1103717))
+
+func utxoKey(hash string, outIndex int) string {
+	return fmt.Sprintf("%s_%d", hash, outIndex)
+}
+
+// encodeUTXO is what we hash into the number of a utxo.
+func encodeUTXO(utxo *UTXO) []byte {
+	return []byte(fmt.Sprintf("%s:%d:%s:%s", utxoKey(utxo.Hash, utxo.OutIndex), utxo.Amount, utxo.Address, utxo.Asset))
+}
+
+// stateElement is the number of a utxo, its sha256 stretched to 3072 bits by hashing it with a counter.
+func stateElement(utxo *UTXO) *big.Int {
+	seed := sha256.Sum256(encodeUTXO(utxo))
+	buf := make([]byte, 0, stateElementLen)
+	for i := uint32(0); len(buf) < stateElementLen; i++ {
+		h := sha256.New()
+		h.Write(seed[:])
+		binary.Write(h, binary.BigEndian, i)
+		buf = h.Sum(buf)
+	}
+	return new(big.Int).Mod(new(big.Int).SetBytes(buf), stateModulus)
+}
+
+// utxoSet is the multiset hash of a utxo set. We keep what we added and what we removed apart, so we
+// only have to invert once, when we want the root.
+type utxoSet struct {
+	added   *big.Int
+	removed *big.Int
+}
+
+func newUTXOSet() *utxoSet {
+	return &utxoSet{
+		added:   big.NewInt(1),
+		removed: big.NewInt(1),
+	}
+}
+
+func (s *utxoSet) copy() *utxoSet {
+	return &utxoSet{
+		added:   new(big.Int).Set(s.added),
+		removed: new(big.Int).Set(s.removed),
+	}
+}
+
+func (s *utxoSet) add(utxo *UTXO) {
+	s.added.Mul(s.added, stateElement(utxo))
+	s.added.Mod(s.added, stateModulus)
+}
+
+func (s *utxoSet) remove(utxo *UTXO) {
+	s.removed.Mul(s.removed, stateElement(utxo))
+	s.removed.Mod(s.removed, stateModulus)
+}
+
+func (s *utxoSet) root() []byte {
+	product := new(big.Int).ModInverse(s.removed, stateModulus)
+	product.Mul(product, s.added)
+	product.Mod(product, stateModulus)
+	hash := sha256.Sum256(product.FillBytes(make([]byte, stateElementLen)))
+	return hash[:]
+}
+
+// applyBlock adds the outputs and removes the inputs of the block the same way Chain.addBlock does with
+// the utxo store. lookup returns the unspent outputs that were there before the block.
+func (s *utxoSet) applyBlock(b *proto.Block, lookup func(key string) (*UTXO, error)) error {
+	var (
+		created = map[string]*UTXO{}
+		spent   = map[string]bool{}
+		txs     = map[string]bool{}
+	)
+	for _, tx := range b.Transactions {
+		hash := hex.EncodeToString(types.HashTransaction(tx))
+		if txs[hash] {
+			return fmt.Errorf("tx %s is in the block twice", hash)
+		}
+		txs[hash] = true
+		for it, output := range tx.Outputs {
+			if types.IsDataOutput(output) {
+				continue
+			}
+			// An output we already have, spent or not, means the tx is on the chain already.
+			if _, err := lookup(utxoKey(hash, it)); err == nil {
+				return fmt.Errorf("tx %s is already on the chain", hash)
+			}
+			utxo := &UTXO{
+				Hash:     hash,
+				Amount:   output.Amount,
+				OutIndex: it,
+				Address:  hex.EncodeToString(output.Address),
+				Asset:    hex.EncodeToString(output.Asset),
+			}
+			created[utxoKey(hash, it)] = utxo
+			s.add(utxo)
+		}
+		for _, input := range tx.Inputs {
+			key := utxoKey(hex.EncodeToString(input.PrevTxHash), int(input.PrevOutIndex))
+			utxo, ok := created[key]
+			if !ok && !spent[key] {
+				if found, err := lookup(key); err == nil && !found.Spent {
+					utxo, ok = found, true
+				}
+			}
+			if !ok {
+				return fmt.Errorf("could not find unspent utxo with hash %s", key)
+			}
+			delete(created, key)
+			spent[key] = true
+			s.remove(utxo)
+		}
+	}
+	return nil
+}
+
+// CalculateStateRoot returns the state root of the given utxos. Spent ones are skipped.
+func CalculateStateRoot(utxos []*UTXO) []byte {
+	set := newUTXOSet()
+	for _, utxo := range utxos {
+		if !utxo.Spent {
+			set.add(utxo)
+		}
+	}
+	return set.root()
+}
+
+// StateRootAfter returns the state root the utxo set will have once the block is added to the chain.
+// Validators put this into the header before signing the block.
+func (c *Chain) StateRootAfter(b *proto.Block) ([]byte, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.stateRootAfter(b)
+}
+
+func (c *Chain) stateRootAfter(b *proto.Block) ([]byte, error) {
+	set := c.utxoSet.copy()
+	if err := set.applyBlock(b, c.utxoStore.Get); err != nil {
+		return nil, err
+	}
+	return set.root(), nil
+}
+
+// StateRoot returns the state root of our current utxo set.
+func (c *Chain) StateRoot() ([]byte, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.utxoSet.root(), nil
+}
@@ -0,0 +1,69 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/types"
+	"github.com/Fito305/blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateStateRoot(t *testing.T) {
+	var (
+		a = &UTXO{Hash: "aa", OutIndex: 0, Amount: 10}
+		b = &UTXO{Hash: "bb", OutIndex: 1, Amount: 20}
+		c = &UTXO{Hash: "cc", OutIndex: 0, Amount: 30, Spent: true}
+	)
+	// The order in which we stored them doesn't matter and spent outputs are not committed.
+	assert.Equal(t, CalculateStateRoot([]*UTXO{a, b}), CalculateStateRoot([]*UTXO{b, c, a}))
+
+	changed := &UTXO{Hash: "bb", OutIndex: 1, Amount: 21}
+	assert.NotEqual(t, CalculateStateRoot([]*UTXO{a, b}), CalculateStateRoot([]*UTXO{a, changed}))
+}
+
+func TestAddBlockInvalidStateRoot(t *testing.T) {
+	var (
+		chain   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+		block   = randomBlock(t, chain)
+		privKey = crypto.GeneratePrivateKey()
+	)
+	block.Header.StateRoot = util.RandomHash()
+	types.SignBlock(privKey, block)
+	require.NotNil(t, chain.AddBlock(block))
+	assert.Equal(t, 0, chain.Height())
+
+	signBlock(t, chain, privKey, block)
+	require.Nil(t, chain.AddBlock(block))
+
+	root, err := chain.StateRoot()
+	require.Nil(t, err)
+	assert.Equal(t, block.Header.StateRoot, root)
+}
+
+func TestStateRootFollowsTheUTXOSet(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	recomputed := func() []byte {
+		utxos, err := chain.utxoStore.List()
+		require.Nil(t, err)
+		return CalculateStateRoot(utxos)
+	}
+	genesisRoot, err := chain.StateRoot()
+	require.Nil(t, err)
+	assert.Equal(t, recomputed(), genesisRoot)
+
+	block := randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, spendGenesisTx(t, chain, crypto.GeneratePrivateKey().Public().Address().Bytes(), 10))
+	signBlock(t, chain, crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+	root, err := chain.StateRoot()
+	require.Nil(t, err)
+	assert.Equal(t, block.Header.StateRoot, root)
+	assert.Equal(t, recomputed(), root)
+
+	require.Nil(t, chain.RollbackBlock())
+	root, err = chain.StateRoot()
+	require.Nil(t, err)
+	assert.Equal(t, genesisRoot, root)
+}
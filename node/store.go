@@ -12,6 +12,8 @@ import (
 type UTXOStorer interface {
 	Put(*UTXO) error
 	Get(string) (*UTXO, error)
+	List() ([]*UTXO, error)
+	Delete(string) error
 }
 
 type MemoryUTXOStore struct {
@@ -47,20 +49,111 @@ func (s *MemoryUTXOStore) Put(utxo *UTXO) error {
 	return nil
 }
 
+func (s *MemoryUTXOStore) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.data, key)
+	return nil
+}
+
+func (s *MemoryUTXOStore) List() ([]*UTXO, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	utxos := make([]*UTXO, 0, len(s.data))
+	for _, utxo := range s.data {
+		utxos = append(utxos, utxo)
+	}
+	return utxos, nil
+}
+
+type AnchorStorer interface {
+	Put(*Anchor) error
+	Get(string) (*Anchor, error)
+	Delete(string) error
+}
+
+type MemoryAnchorStore struct {
+	lock    sync.RWMutex
+	anchors map[string]*Anchor
+}
+
+func NewMemoryAnchorStore() *MemoryAnchorStore {
+	return &MemoryAnchorStore{
+		anchors: make(map[string]*Anchor),
+	}
+}
+
+func (s *MemoryAnchorStore) Get(hash string) (*Anchor, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	anchor, ok := s.anchors[hash]
+	if !ok {
+		return nil, fmt.Errorf("could not find anchor with content hash %s", hash)
+	}
+	return anchor, nil
+}
+
+// Put only keeps the first time some data was anchored, that is the timestamp that counts.
+func (s *MemoryAnchorStore) Put(anchor *Anchor) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.anchors[anchor.ContentHash]; ok {
+		return nil
+	}
+	s.anchors[anchor.ContentHash] = anchor
+	return nil
+}
+
+func (s *MemoryAnchorStore) Delete(hash string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.anchors, hash)
+	return nil
+}
+
 type TXStorer interface {
 	Put(*proto.Transaction) error
 	Get(string) (*proto.Transaction, error)
+	Delete(string) error
+	PutLocation(string, *TxLocation) error
+	GetLocation(string) (*TxLocation, error)
 }
 
 type MemoryTXStore struct {
-	lock sync.RWMutex // make sure it is protected. Make sure we can lock things so we don't have any race conditions.
-	txx map[string]*proto.Transaction
+	lock      sync.RWMutex // make sure it is protected. Make sure we can lock things so we don't have any race conditions.
+	txx       map[string]*proto.Transaction
+	locations map[string]*TxLocation
 }
 
 func NewMemoryTXStore() *MemoryTXStore {
 	return &MemoryTXStore{
-		txx: make(map[string]*proto.Transaction),
+		txx:       make(map[string]*proto.Transaction),
+		locations: make(map[string]*TxLocation),
+	}
+}
+
+func (s *MemoryTXStore) GetLocation(hash string) (*TxLocation, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	loc, ok := s.locations[hash]
+	if !ok {
+		return nil, fmt.Errorf("could not find location of tx with hash %s", hash)
 	}
+	return loc, nil
+}
+
+func (s *MemoryTXStore) PutLocation(hash string, loc *TxLocation) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.locations[hash] = loc
+	return nil
 }
 
 func (s *MemoryTXStore) Get(hash string) (*proto.Transaction, error) {
@@ -82,9 +175,19 @@ func (s *MemoryTXStore) Put(tx *proto.Transaction) error {
 	return nil
 }
 
+func (s *MemoryTXStore) Delete(hash string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.txx, hash)
+	delete(s.locations, hash)
+	return nil
+}
+
 type BlockStorer interface {
 	Put(*proto.Block) error
 	Get(string) (*proto.Block, error)
+	Delete(string) error
 }
 
 type MemoryBlockStore struct {
@@ -116,6 +219,14 @@ func (s *MemoryBlockStore) Get(hash string) (*proto.Block, error) {
 	return block, nil
 }
 
+func (s *MemoryBlockStore) Delete(hash string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.blocks, hash)
+	return nil
+}
+
 // NOTE: If you want to do this with a good implementation that is not in memory you would serialized that into bytes.
 
 // It is better to make a generic interface because the only thing that changes is the underlying data. It gets nast with this many interfaces.
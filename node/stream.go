@@ -0,0 +1,325 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Peers talk over one Connect stream, the node that dials opens it. Everything goes over it in order: the
+// handshake, gossip, pings and their answers. A request has an id, the answer carries it in replyTo, so we can
+// wait for an answer while the peer keeps sending other messages.
+//
+// The readLoop handles what the peer sends us one message at a time, the sendLoop is the only one that writes.
+// It takes what we queued for the peer, a peer that doesn't read lets its queue overflow and gets disconnected.
+// Before we close the stream we tell the peer why with a Disconnect.
+
+const (
+	maxOutQueue  = 256 // Envelopes waiting for the sendLoop, besides the queued Inv items.
+	closeTimeout = time.Second
+)
+
+var errPeerClosed = errors.New("peer disconnected")
+
+// peerStream is our side of a Connect, the grpc client stream when we dialed the peer, the server stream when it dialed us.
+type peerStream interface {
+	Send(*proto.Envelope) error
+	Recv() (*proto.Envelope, error)
+	Context() context.Context
+}
+
+func (n *Node) Connect(stream proto.PeerService_ConnectServer) error {
+	return n.servePeer(stream)
+}
+
+// servePeer does the handshake with a node that dialed us and talks to it until one of us disconnects.
+func (n *Node) servePeer(stream peerStream) error {
+	p, err := n.acceptPeer(stream)
+	if err != nil || p == nil {
+		return err
+	}
+	n.runPeer(p)
+	return nil
+}
+
+// acceptPeer is our side of the handshake of a node that dialed us. It returns a nil peer when that node is us.
+func (n *Node) acceptPeer(stream peerStream) (*remotePeer, error) {
+	ctx := stream.Context()
+	nonce := util.RandomHash()[:challengeLen]
+	if err := stream.Send(&proto.Envelope{Msg: &proto.Envelope_Challenge{
+		Challenge: &proto.Challenge{Nonce: nonce, NodeId: n.nodeID},
+	}}); err != nil {
+		return nil, err
+	}
+	env, err := recvWithin(stream, handshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	v := env.GetVersion()
+	if v == nil {
+		return nil, status.Error(codes.InvalidArgument, "handshake first")
+	}
+
+	observedIP := remoteIP(ctx)
+	addr, err := canonicalAddr(v.ListenAddr, observedIP)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if n.bans.isBanned(observedIP) || n.bans.isBanned(addr) {
+		return nil, status.Error(codes.PermissionDenied, "banned")
+	}
+	if len(v.Challenge) != challengeLen {
+		return nil, status.Error(codes.InvalidArgument, "no challenge for us to sign")
+	}
+	if err := verifyVersion(nonce, v); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if remote, ok := peer.FromContext(ctx); ok && n.peerTLS != nil && !bytes.Equal(certNodeID(remote.AuthInfo), v.NodeId) {
+		return nil, status.Error(codes.Unauthenticated, "tls certificate is not of the node")
+	}
+	resp := n.getVersion()
+	resp.ObservedIp = observedIP
+	signVersion(n.NodeKey, v.Challenge, resp)
+	respEnv := &proto.Envelope{Msg: &proto.Envelope_Version{Version: resp}}
+	if bytes.Equal(v.NodeId, n.nodeID) {
+		// We dialed ourselves. Answer without adding a peer, our side of the call sees its own nodeId and remembers the address.
+		return nil, stream.Send(respEnv)
+	}
+	if err := n.checkVersion(v); err != nil {
+		n.logger.Infow("rejected remote node", "remoteNode", addr, "err", err)
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	v.ListenAddr = addr // From now on the peer is known by the address we can dial it at.
+
+	// The peer is added before it gets our version, once it has it, it can count on us.
+	p := newRemotePeer(stream, v, false)
+	if err := n.addPeer(p); err != nil {
+		return nil, err
+	}
+	if err := stream.Send(respEnv); err != nil {
+		n.removePeer(p, "handshake failed")
+		return nil, err
+	}
+	// The address is only what the peer claims, so its source is the ip it really connected from.
+	// Otherwise one host could pick the source group of every address it feeds us.
+	n.addrs.add([]string{v.ListenAddr}, observedIP)
+	return p, nil
+}
+
+// recvWithin is stream.Recv, giving up after d. A server stream can't be canceled, the Recv goes on until the stream ends.
+func recvWithin(stream peerStream, d time.Duration) (*proto.Envelope, error) {
+	type result struct {
+		env *proto.Envelope
+		err error
+	}
+	c := make(chan result, 1)
+	go func() {
+		env, err := stream.Recv()
+		c <- result{env, err}
+	}()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case r := <-c:
+		return r.env, r.err
+	case <-timer.C:
+		return nil, status.Error(codes.DeadlineExceeded, "handshake timed out")
+	}
+}
+
+// runPeer talks to p until it gets disconnected, then closes the stream.
+func (n *Node) runPeer(p *remotePeer) {
+	var (
+		sent = make(chan struct{})
+		read = make(chan struct{})
+	)
+	go func() {
+		n.sendLoop(p)
+		close(sent)
+	}()
+	go func() {
+		n.readLoop(p)
+		close(read)
+	}()
+	<-p.quit
+
+	// Give the Disconnect a moment to get out and the peer to close its side.
+	timeout := time.NewTimer(closeTimeout)
+	defer timeout.Stop()
+	select {
+	case <-sent:
+	case <-timeout.C:
+	}
+	select {
+	case <-read:
+	case <-timeout.C:
+	}
+	p.release()
+	close(p.done)
+}
+
+// sendLoop writes everything we send p, until p gets disconnected. The Inv items queued with announce go out
+// every invInterval.
+func (n *Node) sendLoop(p *remotePeer) {
+	ticker := time.NewTicker(invInterval)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case env := <-p.out:
+			err = p.stream.Send(env)
+		case <-ticker.C:
+			err = n.sendInv(p, p.takeInv())
+		case <-p.quit:
+			p.stream.Send(&proto.Envelope{Msg: &proto.Envelope_Disconnect{Disconnect: &proto.Disconnect{Reason: p.reason}}})
+			return
+		}
+		if err != nil {
+			n.removePeer(p, "connection lost")
+			return
+		}
+	}
+}
+
+func (n *Node) sendInv(p *remotePeer, items []*proto.InvItem) error {
+	for len(items) > 0 {
+		batch := items[:min(len(items), maxInvItems)]
+		items = items[len(batch):]
+		if err := p.stream.Send(&proto.Envelope{Msg: &proto.Envelope_Inv{Inv: &proto.Inv{Items: batch}}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLoop handles what p sends us in the order it was sent, until p gets disconnected. A peer that breaks the
+// protocol gets disconnected.
+func (n *Node) readLoop(p *remotePeer) {
+	for {
+		env, err := p.stream.Recv()
+		if err != nil {
+			n.removePeer(p, "connection lost")
+			return
+		}
+		if d := env.GetDisconnect(); d != nil {
+			n.removePeer(p, "remote node disconnected: "+d.Reason)
+			return
+		}
+		if err := n.handleEnvelope(p, env); err != nil {
+			n.removePeer(p, err.Error())
+			return
+		}
+	}
+}
+
+func (n *Node) handleEnvelope(p *remotePeer, env *proto.Envelope) error {
+	if n.peerGuard != nil {
+		if err := n.peerGuard.allow(remoteIP(p.stream.Context())); err != nil {
+			return err
+		}
+	}
+	if env.ReplyTo != 0 {
+		p.reply(env)
+		return nil
+	}
+	from := p.banAddr()
+	switch msg := env.Msg.(type) {
+	case *proto.Envelope_Transaction:
+		if err := n.receiveTransaction(from, msg.Transaction); err != nil {
+			n.logger.Debugw("rejected tx", "we", n.ListenAddr, "remoteNode", p.version.ListenAddr, "err", err)
+		}
+	case *proto.Envelope_Block:
+		if err := n.receiveBlock(from, msg.Block); err != nil {
+			n.logger.Debugw("rejected block", "we", n.ListenAddr, "remoteNode", p.version.ListenAddr, "err", err)
+		}
+	case *proto.Envelope_Vote:
+		if err := n.receiveVote(from, msg.Vote); err != nil {
+			n.logger.Debugw("rejected vote", "we", n.ListenAddr, "remoteNode", p.version.ListenAddr, "err", err)
+		}
+	case *proto.Envelope_Inv:
+		return n.handleInv(p, msg.Inv)
+	case *proto.Envelope_GetData:
+		if len(msg.GetData.Items) > maxInvItems {
+			return fmt.Errorf("asked for more than (%d) items", maxInvItems)
+		}
+		return p.answer(env, &proto.Envelope{Msg: &proto.Envelope_InvData{InvData: n.getData(msg.GetData.Items)}})
+	case *proto.Envelope_Ping:
+		n.peerLock.Lock()
+		p.version.Height = msg.Ping.Height
+		n.peerLock.Unlock()
+		return p.answer(env, &proto.Envelope{Msg: &proto.Envelope_Pong{Pong: &proto.Pong{Height: int32(n.chain.Height())}}})
+	case *proto.Envelope_GetAddrs:
+		return p.answer(env, &proto.Envelope{Msg: &proto.Envelope_AddrList{AddrList: &proto.AddrList{Addrs: n.addrs.sample(maxAddrsPerMessage)}}})
+	default:
+		return fmt.Errorf("unexpected %T", env.Msg)
+	}
+	return nil
+}
+
+// send queues env for the sendLoop. It reports false when the queue of p is full.
+func (p *remotePeer) send(env *proto.Envelope) bool {
+	select {
+	case p.out <- env:
+		return true
+	default:
+		return false
+	}
+}
+
+// answer sends env as the answer to the request req.
+func (p *remotePeer) answer(req *proto.Envelope, env *proto.Envelope) error {
+	if req.Id == 0 {
+		return nil // Nobody waits for it.
+	}
+	env.ReplyTo = req.Id
+	if !p.send(env) {
+		return errors.New("too slow, send queue full")
+	}
+	return nil
+}
+
+// call sends req to p and waits for the answer.
+func (p *remotePeer) call(ctx context.Context, req *proto.Envelope) (*proto.Envelope, error) {
+	reply := make(chan *proto.Envelope, 1)
+	p.callLock.Lock()
+	p.nextID++
+	req.Id = p.nextID
+	p.calls[req.Id] = reply
+	p.callLock.Unlock()
+	defer func() {
+		p.callLock.Lock()
+		delete(p.calls, req.Id)
+		p.callLock.Unlock()
+	}()
+
+	if !p.send(req) {
+		return nil, errors.New("send queue full")
+	}
+	select {
+	case env := <-reply:
+		return env, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.quit:
+		return nil, errPeerClosed
+	}
+}
+
+// reply hands the answer env to the call waiting for it. Answers nobody waits for anymore are dropped.
+func (p *remotePeer) reply(env *proto.Envelope) {
+	p.callLock.Lock()
+	reply, ok := p.calls[env.ReplyTo]
+	delete(p.calls, env.ReplyTo)
+	p.callLock.Unlock()
+	if ok {
+		reply <- env
+	}
+}
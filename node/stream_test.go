@@ -0,0 +1,88 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeersTalkOverOneStream(t *testing.T) {
+	var (
+		n   = NewNode(ServerConfig{})
+		ctx = context.Background()
+	)
+	_, pipe, err := connectPipe(t, ctx, n, remoteVersion(n, crypto.GeneratePrivateKey(), "127.0.0.1:4000"))
+	require.Nil(t, err)
+	peers := n.peerList()
+	require.Len(t, peers, 1)
+
+	// Our requests get answered with their id.
+	require.Nil(t, pipe.Send(&proto.Envelope{Id: 7, Msg: &proto.Envelope_Ping{Ping: &proto.PingRequest{Height: 3}}}))
+	env, err := pipe.Recv()
+	require.Nil(t, err)
+	assert.Equal(t, uint64(7), env.ReplyTo)
+	require.NotNil(t, env.GetPong())
+	assert.Equal(t, int32(0), env.GetPong().Height)
+
+	// And the other way around, while other messages go by.
+	answer := make(chan *proto.Envelope, 1)
+	go func() {
+		env, err := peers[0].call(ctx, &proto.Envelope{Msg: &proto.Envelope_GetAddrs{GetAddrs: &proto.GetAddrsRequest{}}})
+		assert.Nil(t, err)
+		answer <- env
+	}()
+	req, err := pipe.Recv()
+	require.Nil(t, err)
+	require.NotNil(t, req.GetGetAddrs())
+	require.Nil(t, pipe.Send(&proto.Envelope{Msg: &proto.Envelope_Inv{Inv: &proto.Inv{}}}))
+	require.Nil(t, pipe.Send(&proto.Envelope{ReplyTo: req.Id, Msg: &proto.Envelope_AddrList{AddrList: &proto.AddrList{Addrs: []string{"10.0.0.1:3000"}}}}))
+	select {
+	case env := <-answer:
+		assert.Equal(t, []string{"10.0.0.1:3000"}, env.GetAddrList().Addrs)
+	case <-time.After(time.Second):
+		t.Fatal("no answer")
+	}
+
+	// Breaking the protocol gets us disconnected, we hear why.
+	require.Nil(t, pipe.Send(&proto.Envelope{Msg: &proto.Envelope_Challenge{Challenge: &proto.Challenge{}}}))
+	env, err = pipe.Recv()
+	require.Nil(t, err)
+	require.NotNil(t, env.GetDisconnect())
+	assert.Contains(t, env.GetDisconnect().Reason, "unexpected")
+	assert.Empty(t, n.getPeerList())
+}
+
+func TestPeersSendVotes(t *testing.T) {
+	var (
+		validator = crypto.GeneratePrivateKey()
+		genesis   = DefaultGenesis()
+	)
+	genesis.Validators = []string{hex.EncodeToString(validator.Public().Bytes())}
+	n := NewNode(ServerConfig{ListenAddr: "127.0.0.1:3000", Genesis: genesis})
+	_, pipe, err := connectPipe(t, guardContext("10.0.0.9", ""), n, remoteVersion(n, crypto.GeneratePrivateKey(), "10.0.0.9:4000"))
+	require.Nil(t, err)
+
+	// A vote of a validator is fine, nothing does anything with it yet.
+	vote := &proto.Vote{BlockHash: n.chain.GenesisHash(), Height: 0}
+	types.SignVote(validator, vote)
+	require.Nil(t, pipe.Send(&proto.Envelope{Msg: &proto.Envelope_Vote{Vote: vote}}))
+	require.Nil(t, pipe.Send(&proto.Envelope{Id: 1, Msg: &proto.Envelope_Ping{Ping: &proto.PingRequest{}}}))
+	env, err := pipe.Recv()
+	require.Nil(t, err)
+	require.NotNil(t, env.GetPong())
+	assert.Zero(t, n.bans.score("10.0.0.9"))
+
+	// Anybody else's vote is made up.
+	types.SignVote(crypto.GeneratePrivateKey(), vote)
+	require.Nil(t, pipe.Send(&proto.Envelope{Msg: &proto.Envelope_Vote{Vote: vote}}))
+	require.Eventually(t, func() bool {
+		return n.bans.score("10.0.0.9") == scoreInvalidVote
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,217 @@
+package node
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/Fito305/blocker/crypto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Peers do mutual TLS with a certificate of their NodeKey: self signed, or in a permissioned network signed by
+// the CA of the network. Node certificates have no host names, a node is its key. The handshake checks the key of
+// the certificate is the nodeId the other side proved it owns. The PublicAPI, the AdminAPI and the JSON API use
+// normal certificates, their clients check the host name.
+
+const nodeCertValidity = 10 * 365 * 24 * time.Hour
+
+// TLSConfig turns on TLS for a listener, nil means plaintext.
+type TLSConfig struct {
+	// The certificate and its key. The PeerService only uses the CertFile, a certificate of the NodeKey.
+	// A self signed certificate of the NodeKey when empty.
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// CAFile makes the other side show a certificate signed by this CA. For the PeerService that's a
+	// permissioned network, for the APIs client certificates.
+	CAFile string `json:"caFile"`
+}
+
+// NodeCertificate is a self signed certificate of key.
+func NodeCertificate(key *crypto.PrivateKey) (tls.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	priv := ed25519.NewKeyFromSeed(key.Seed())
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: nodeIDString(key.Public().Bytes())},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(nodeCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, priv.Public(), priv)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv, Leaf: leaf}, nil
+}
+
+// PeerTLSConfig is the tls config of both sides of a peer connection for the node with key.
+func PeerTLSConfig(key *crypto.PrivateKey, cfg TLSConfig) (*tls.Config, error) {
+	var (
+		cert tls.Certificate
+		err  error
+	)
+	if cfg.CertFile != "" {
+		cert, err = loadNodeCertificate(cfg.CertFile, key)
+	} else {
+		cert, err = NodeCertificate(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var roots *x509.CertPool
+	if cfg.CAFile != "" {
+		if roots, err = loadCertPool(cfg.CAFile); err != nil {
+			return nil, err
+		}
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+		// Node certificates have no host names, verifyNodeCertificate checks them instead.
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyNodeCertificate(rawCerts, roots)
+		},
+		MinVersion: tls.VersionTLS13,
+	}, nil
+}
+
+// loadNodeCertificate reads the certificate chain in path, the first one must be of key.
+func loadNodeCertificate(path string, key *crypto.PrivateKey) (tls.Certificate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cert := tls.Certificate{PrivateKey: ed25519.NewKeyFromSeed(key.Seed())}
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+	if len(cert.Certificate) == 0 {
+		return cert, fmt.Errorf("no certificate in %s", path)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return cert, fmt.Errorf("invalid certificate in %s: %w", path, err)
+	}
+	if pub, ok := cert.Leaf.PublicKey.(ed25519.PublicKey); !ok || !bytes.Equal(pub, key.Public().Bytes()) {
+		return cert, fmt.Errorf("%s is not a certificate of the node key", path)
+	}
+	return cert, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificate in %s", path)
+	}
+	return pool, nil
+}
+
+// verifyNodeCertificate checks the certificate of a peer is of an ed25519 key and, with roots, signed by the CA.
+func verifyNodeCertificate(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("no node certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	if _, ok := certs[0].PublicKey.(ed25519.PublicKey); !ok {
+		return errors.New("node certificate is not of an ed25519 key")
+	}
+	if roots == nil {
+		return nil
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+// certNodeID is the nodeId of the certificate the other side of a connection showed, nil without TLS.
+func certNodeID(info credentials.AuthInfo) []byte {
+	tlsInfo, ok := info.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return nil
+	}
+	pub, _ := tlsInfo.State.PeerCertificates[0].PublicKey.(ed25519.PublicKey)
+	return pub
+}
+
+// apiServerOptions are the grpc options of the PublicAPI or the AdminAPI for cfg, none without TLS.
+func (n *Node) apiServerOptions(cfg *TLSConfig) ([]grpc.ServerOption, error) {
+	tlsConfig, err := n.apiTLSConfig(cfg)
+	if err != nil || tlsConfig == nil {
+		return nil, err
+	}
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(tlsConfig))}, nil
+}
+
+// apiTLSConfig is the tls config of an API listener for cfg, nil without TLS.
+func (n *Node) apiTLSConfig(cfg *TLSConfig) (*tls.Config, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	var (
+		cert tls.Certificate
+		err  error
+	)
+	if cfg.CertFile != "" {
+		cert, err = tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	} else {
+		cert, err = NodeCertificate(n.NodeKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.CAFile != "" {
+		if tlsConfig.ClientCAs, err = loadCertPool(cfg.CAFile); err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,232 @@
+package node
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Fito305/blocker/client"
+	"github.com/Fito305/blocker/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA issues certificates, it is the CA of a permissioned network.
+type testCA struct {
+	t    *testing.T
+	dir  string
+	cert *x509.Certificate
+	key  ed25519.PrivateKey
+	file string // The CA certificate.
+}
+
+func newTestCA(t *testing.T) *testCA {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	ca := &testCA{t: t, dir: t.TempDir(), key: key}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "blocker test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.Nil(t, err)
+	ca.cert, err = x509.ParseCertificate(der)
+	require.Nil(t, err)
+	ca.file = ca.writePEM("ca.pem", "CERTIFICATE", der)
+	return ca
+}
+
+// issue signs a certificate of pub and returns its file.
+func (ca *testCA) issue(name string, pub ed25519.PublicKey, ips ...net.IP) string {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, pub, ca.key)
+	require.Nil(ca.t, err)
+	return ca.writePEM(name+".pem", "CERTIFICATE", der)
+}
+
+// issueKeyPair is issue for a new key, it returns the files of the certificate and the key.
+func (ca *testCA) issueKeyPair(name string, ips ...net.IP) (string, string) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(ca.t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.Nil(ca.t, err)
+	return ca.issue(name, pub, ips...), ca.writePEM(name+".key", "PRIVATE KEY", der)
+}
+
+func (ca *testCA) writePEM(name string, typ string, der []byte) string {
+	path := filepath.Join(ca.dir, name)
+	require.Nil(ca.t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600))
+	return path
+}
+
+func TestLoadNodeCertificate(t *testing.T) {
+	var (
+		ca    = newTestCA(t)
+		key   = crypto.GeneratePrivateKey()
+		other = crypto.GeneratePrivateKey()
+		file  = ca.issue("node", key.Public().Bytes())
+	)
+	cert, err := loadNodeCertificate(file, key)
+	require.Nil(t, err)
+	assert.Equal(t, "node", cert.Leaf.Subject.CommonName)
+
+	_, err = loadNodeCertificate(file, other)
+	assert.NotNil(t, err)
+	_, err = loadNodeCertificate(ca.dir+"/missing.pem", key)
+	assert.NotNil(t, err)
+}
+
+func TestPeersConnectOverMutualTLS(t *testing.T) {
+	var (
+		addrA = freeAddr(t)
+		addrB = freeAddr(t)
+		addrC = freeAddr(t)
+		a     = NewNode(ServerConfig{PeerTLS: &TLSConfig{}})
+		b     = NewNode(ServerConfig{PeerTLS: &TLSConfig{}})
+		c     = NewNode(ServerConfig{}) // Plaintext.
+	)
+	defer a.Stop()
+	defer b.Stop()
+	defer c.Stop()
+
+	go a.Start(addrA, nil)
+	waitForListener(t, addrA)
+	go b.Start(addrB, []string{addrA})
+	go c.Start(addrC, []string{addrA})
+
+	require.Eventually(t, func() bool {
+		return len(a.getPeerList()) == 1 && len(b.getPeerList()) == 1
+	}, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{addrB}, a.getPeerList())
+	assert.Never(t, func() bool {
+		return len(c.getPeerList()) > 0
+	}, 300*time.Millisecond, 10*time.Millisecond)
+}
+
+func TestPermissionedNetworkOnlyAcceptsCertificatesOfItsCA(t *testing.T) {
+	var (
+		ca    = newTestCA(t)
+		addrA = freeAddr(t)
+		addrB = freeAddr(t)
+		addrC = freeAddr(t)
+		keyA  = crypto.GeneratePrivateKey()
+		keyB  = crypto.GeneratePrivateKey()
+		a     = NewNode(ServerConfig{NodeKey: keyA, PeerTLS: &TLSConfig{CertFile: ca.issue("a", keyA.Public().Bytes()), CAFile: ca.file}})
+		b     = NewNode(ServerConfig{NodeKey: keyB, PeerTLS: &TLSConfig{CertFile: ca.issue("b", keyB.Public().Bytes()), CAFile: ca.file}})
+		// c trusts the CA, but the CA didn't give it a certificate.
+		c = NewNode(ServerConfig{PeerTLS: &TLSConfig{CAFile: ca.file}})
+	)
+	defer a.Stop()
+	defer b.Stop()
+	defer c.Stop()
+
+	go a.Start(addrA, nil)
+	waitForListener(t, addrA)
+	go b.Start(addrB, []string{addrA})
+	go c.Start(addrC, []string{addrA})
+
+	require.Eventually(t, func() bool {
+		return len(a.getPeerList()) == 1 && len(b.getPeerList()) == 1
+	}, 3*time.Second, 10*time.Millisecond)
+	assert.Never(t, func() bool {
+		return len(c.getPeerList()) > 0 || len(a.getPeerList()) > 1
+	}, 300*time.Millisecond, 10*time.Millisecond)
+}
+
+func TestPublicAPIOverTLSWithClientCertificates(t *testing.T) {
+	var (
+		ca                 = newTestCA(t)
+		addr               = freeAddr(t)
+		publicAddr         = freeAddr(t)
+		serverCert, srvKey = ca.issueKeyPair("server", net.ParseIP("127.0.0.1"))
+		clientCert, cliKey = ca.issueKeyPair("client")
+		n                  = NewNode(ServerConfig{
+			PublicListenAddr: publicAddr,
+			PublicTLS:        &TLSConfig{CertFile: serverCert, KeyFile: srvKey, CAFile: ca.file},
+		})
+	)
+	defer n.Stop()
+	go n.Start(addr, nil)
+	waitForListener(t, publicAddr)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	cert, err := tls.LoadX509KeyPair(clientCert, cliKey)
+	require.Nil(t, err)
+
+	c, err := client.Dial(publicAddr, client.WithRetries(0, 0), client.WithTLS(&tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{cert},
+	}))
+	require.Nil(t, err)
+	defer c.Close()
+	_, err = c.GetBlockByHeight(context.Background(), 0)
+	require.Nil(t, err)
+
+	// Without a client certificate, and without tls.
+	anonymous, err := client.Dial(publicAddr, client.WithRetries(0, 0), client.WithTLS(&tls.Config{RootCAs: roots}))
+	require.Nil(t, err)
+	defer anonymous.Close()
+	_, err = anonymous.GetBlockByHeight(context.Background(), 0)
+	assert.NotNil(t, err)
+
+	plain, err := client.Dial(publicAddr, client.WithRetries(0, 0))
+	require.Nil(t, err)
+	defer plain.Close()
+	_, err = plain.GetBlockByHeight(context.Background(), 0)
+	assert.NotNil(t, err)
+}
+
+func TestJSONAPIOverTLS(t *testing.T) {
+	var (
+		ca                 = newTestCA(t)
+		addr               = freeAddr(t)
+		apiAddr            = freeAddr(t)
+		serverCert, srvKey = ca.issueKeyPair("server", net.ParseIP("127.0.0.1"))
+		n                  = NewNode(ServerConfig{
+			APIListenAddr: apiAddr,
+			APITLS:        &TLSConfig{CertFile: serverCert, KeyFile: srvKey},
+		})
+	)
+	defer n.Stop()
+	go n.Start(addr, nil)
+	waitForListener(t, apiAddr)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := c.Get("https://" + apiAddr + "/status")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Plain http only gets told to use https.
+	resp, err = http.Get("http://" + apiAddr + "/status")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
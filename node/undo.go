@@ -0,0 +1,134 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/Fito305/blocker/types"
+)
+
+// blockUndo is everything we need to put the chain back the way it was before a block was added.
+type blockUndo struct {
+	created []string // keys of the utxos the block created.
+	spent   []*UTXO  // copies of the utxos the block spent, before we marked them spent.
+	anchors []string // content hashes anchored for the first time in the block.
+}
+
+// EnablePruning makes the chain forget the block bodies, transactions and undo data
+// of everything older than the last keep blocks. Headers and the utxo set are always kept.
+func (c *Chain) EnablePruning(keep int) error {
+	if keep < 1 {
+		return fmt.Errorf("we need to keep at least 1 block, got (%d)", keep)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.pruneDepth = keep
+	return c.prune()
+}
+
+func (c *Chain) IsPruned() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.pruneDepth > 0
+}
+
+// LowestHeight returns the height of the oldest block we can still serve.
+func (c *Chain) LowestHeight() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lowestHeight
+}
+
+// prune needs the lock.
+func (c *Chain) prune() error {
+	if c.pruneDepth == 0 {
+		return nil
+	}
+	for ; c.lowestHeight <= c.headers.Height()-c.pruneDepth; c.lowestHeight++ {
+		height := c.lowestHeight
+		b, err := c.blockByHeight(height)
+		if err != nil {
+			return err
+		}
+		for _, tx := range b.Transactions {
+			if err := c.txStore.Delete(hex.EncodeToString(types.HashTransaction(tx))); err != nil {
+				return err
+			}
+		}
+		// Once we can't roll back the block anymore, nobody needs the outputs it spent.
+		if undo, ok := c.undo[height]; ok {
+			for _, utxo := range undo.spent {
+				if err := c.utxoStore.Delete(utxoKey(utxo.Hash, utxo.OutIndex)); err != nil {
+					return err
+				}
+			}
+			delete(c.undo, height)
+		}
+		if err := c.blockStore.Delete(hex.EncodeToString(types.HashBlock(b))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RollbackBlock removes the block at the tip of the chain and puts the utxo set back
+// the way it was before it. We can only roll back blocks we still have undo data for.
+func (c *Chain) RollbackBlock() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	height := c.headers.Height()
+	if height == 0 {
+		return fmt.Errorf("cannot roll back the genesis block")
+	}
+	undo, ok := c.undo[height]
+	if !ok || height-1 < c.lowestHeight { // We need to keep the body of the new tip to validate the next block against.
+		return fmt.Errorf("no undo data for block at height (%d)", height)
+	}
+	b, err := c.blockByHeight(height)
+	if err != nil {
+		return err
+	}
+
+	// Restore the spent ones first, an output created and spent in the same block has to be gone in the end.
+	for _, utxo := range undo.spent {
+		restored := *utxo
+		if err := c.utxoStore.Put(&restored); err != nil {
+			return err
+		}
+		c.utxoSet.add(&restored)
+	}
+	for _, key := range undo.created {
+		utxo, err := c.utxoStore.Get(key)
+		if err != nil {
+			return err
+		}
+		if err := c.utxoStore.Delete(key); err != nil {
+			return err
+		}
+		c.utxoSet.remove(utxo)
+	}
+	for _, hash := range undo.anchors {
+		if err := c.anchorStore.Delete(hash); err != nil {
+			return err
+		}
+		delete(c.anchorProof, hash)
+	}
+	for _, tx := range b.Transactions {
+		if err := c.txStore.Delete(hex.EncodeToString(types.HashTransaction(tx))); err != nil {
+			return err
+		}
+	}
+	if err := c.blockStore.Delete(hex.EncodeToString(types.HashBlock(b))); err != nil {
+		return err
+	}
+	for _, indexer := range c.indexers {
+		if err := indexer.RollbackBlock(b, height); err != nil {
+			return err
+		}
+	}
+	delete(c.undo, height)
+	c.headers.RemoveLast()
+	return nil
+}
@@ -0,0 +1,113 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollbackBlock(t *testing.T) {
+	var (
+		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+		privKey   = crypto.GeneratePrivateKey()
+		recipient = crypto.GeneratePrivateKey().Public().Address().Bytes()
+	)
+	rootBefore, err := chain.StateRoot()
+	require.Nil(t, err)
+
+	block := randomBlock(t, chain)
+	tx := spendGenesisTx(t, chain, recipient, 100)
+	block.Transactions = append(block.Transactions, tx)
+	signBlock(t, chain, privKey, block)
+	require.Nil(t, chain.AddBlock(block))
+
+	balance, err := chain.GetBalance(recipient)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(100), balance[NativeAsset])
+
+	require.Nil(t, chain.RollbackBlock())
+	assert.Equal(t, 0, chain.Height())
+	rootAfter, err := chain.StateRoot()
+	require.Nil(t, err)
+	assert.Equal(t, rootBefore, rootAfter)
+	_, err = chain.GetBlockByHash(types.HashBlock(block))
+	assert.NotNil(t, err)
+
+	// The genesis output is unspent again, so we can spend it in another block.
+	block = randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, spendGenesisTx(t, chain, recipient, 200))
+	signBlock(t, chain, privKey, block)
+	require.Nil(t, chain.AddBlock(block))
+
+	require.Nil(t, chain.RollbackBlock())
+	assert.NotNil(t, chain.RollbackBlock()) // The genesis block can never be rolled back.
+}
+
+func TestPruning(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore(), DefaultGenesis())
+	genesis, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	require.Nil(t, chain.EnablePruning(10))
+
+	block := randomBlock(t, chain)
+	tx := spendGenesisTx(t, chain, crypto.GeneratePrivateKey().Public().Address().Bytes(), 100)
+	block.Transactions = append(block.Transactions, tx)
+	signBlock(t, chain, crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+	for i := 0; i < 50; i++ {
+		require.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	}
+
+	assert.Equal(t, 51, chain.Height())
+	assert.Equal(t, 42, chain.LowestHeight())
+	assert.True(t, chain.IsPruned())
+
+	// Headers are kept, old bodies and transactions are gone.
+	assert.Equal(t, types.HashBlock(genesis), types.HashHeader(chain.headers.Get(0)))
+	_, err = chain.GetBlockByHeight(1)
+	assert.NotNil(t, err)
+	_, err = chain.txStore.Get(hex.EncodeToString(types.HashTransaction(tx)))
+	assert.NotNil(t, err)
+	for height := 42; height <= 51; height++ {
+		_, err := chain.GetBlockByHeight(height)
+		assert.Nil(t, err)
+	}
+
+	// The spent genesis output is gone, the utxo set still matches the header.
+	root, err := chain.StateRoot()
+	require.Nil(t, err)
+	assert.Equal(t, chain.headers.Get(chain.Height()).StateRoot, root)
+	utxos, err := chain.utxoStore.List()
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(utxos))
+
+	// We can roll back as long as we have undo data.
+	for i := 0; i < 9; i++ {
+		require.Nil(t, chain.RollbackBlock())
+	}
+	assert.NotNil(t, chain.RollbackBlock())
+}
+
+func TestGetPeersShowsPrunedPeers(t *testing.T) {
+	var (
+		n   = NewNode(ServerConfig{ListenAddr: "127.0.0.1:3000"})
+		ctx = context.Background()
+	)
+	_, err := handshake(t, ctx, n, crypto.GeneratePrivateKey(), "127.0.0.1:4000", func(v *proto.Version) {
+		v.Pruned = true
+		v.LowestHeight = 42
+	})
+	require.Nil(t, err)
+
+	peers, err := n.GetPeers(ctx, &proto.PeersRequest{})
+	require.Nil(t, err)
+	require.Len(t, peers.Peers, 1)
+	assert.True(t, peers.Peers[0].Pruned)
+	assert.Equal(t, int32(42), peers.Peers[0].LowestHeight)
+}
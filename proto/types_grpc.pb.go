@@ -19,132 +19,885 @@ import (
 const _ = grpc.SupportPackageIsVersion8
 
 const (
-	Node_Handshake_FullMethodName         = "/Node/Handshake"
-	Node_HandleTransaction_FullMethodName = "/Node/HandleTransaction"
+	PeerService_Connect_FullMethodName           = "/PeerService/Connect"
+	PeerService_HandleTransaction_FullMethodName = "/PeerService/HandleTransaction"
+	PeerService_GetSnapshot_FullMethodName       = "/PeerService/GetSnapshot"
+	PeerService_HandleBlock_FullMethodName       = "/PeerService/HandleBlock"
 )
 
-// NodeClient is the client API for Node service.
+// PeerServiceClient is the client API for PeerService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 //
 // Basically how GRPC works is we have this proto file. We have this HandleTransaction, and this is a node. We need to create a listener then we need to create our GRPC server, which will take in a GRPC server itself but also it will take in some kind of implementation of this node.
-type NodeClient interface {
-	Handshake(ctx context.Context, in *Version, opts ...grpc.CallOption) (*Version, error)
+// PeerService is what nodes use to talk to each other. It runs on ServerConfig.ListenAddr.
+type PeerServiceClient interface {
+	// Connect is the connection between two peers, one stream each way for as long as they are connected.
+	// The caller sends the first Envelope, see Envelope for the handshake.
+	Connect(ctx context.Context, opts ...grpc.CallOption) (PeerService_ConnectClient, error)
 	HandleTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Ack, error)
+	GetSnapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*Snapshot, error)
+	HandleBlock(ctx context.Context, in *Block, opts ...grpc.CallOption) (*Ack, error)
 }
 
-type nodeClient struct {
+type peerServiceClient struct {
 	cc grpc.ClientConnInterface
 }
 
-func NewNodeClient(cc grpc.ClientConnInterface) NodeClient {
-	return &nodeClient{cc}
+func NewPeerServiceClient(cc grpc.ClientConnInterface) PeerServiceClient {
+	return &peerServiceClient{cc}
 }
 
-func (c *nodeClient) Handshake(ctx context.Context, in *Version, opts ...grpc.CallOption) (*Version, error) {
+func (c *peerServiceClient) Connect(ctx context.Context, opts ...grpc.CallOption) (PeerService_ConnectClient, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(Version)
-	err := c.cc.Invoke(ctx, Node_Handshake_FullMethodName, in, out, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &PeerService_ServiceDesc.Streams[0], PeerService_Connect_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &peerServiceConnectClient{ClientStream: stream}
+	return x, nil
+}
+
+type PeerService_ConnectClient interface {
+	Send(*Envelope) error
+	Recv() (*Envelope, error)
+	grpc.ClientStream
+}
+
+type peerServiceConnectClient struct {
+	grpc.ClientStream
+}
+
+func (x *peerServiceConnectClient) Send(m *Envelope) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *peerServiceConnectClient) Recv() (*Envelope, error) {
+	m := new(Envelope)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *peerServiceClient) HandleTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Ack, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Ack)
+	err := c.cc.Invoke(ctx, PeerService_HandleTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *peerServiceClient) GetSnapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*Snapshot, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Snapshot)
+	err := c.cc.Invoke(ctx, PeerService_GetSnapshot_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *nodeClient) HandleTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Ack, error) {
+func (c *peerServiceClient) HandleBlock(ctx context.Context, in *Block, opts ...grpc.CallOption) (*Ack, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(Ack)
-	err := c.cc.Invoke(ctx, Node_HandleTransaction_FullMethodName, in, out, cOpts...)
+	err := c.cc.Invoke(ctx, PeerService_HandleBlock_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// NodeServer is the server API for Node service.
-// All implementations must embed UnimplementedNodeServer
+// PeerServiceServer is the server API for PeerService service.
+// All implementations must embed UnimplementedPeerServiceServer
 // for forward compatibility
 //
 // Basically how GRPC works is we have this proto file. We have this HandleTransaction, and this is a node. We need to create a listener then we need to create our GRPC server, which will take in a GRPC server itself but also it will take in some kind of implementation of this node.
-type NodeServer interface {
-	Handshake(context.Context, *Version) (*Version, error)
+// PeerService is what nodes use to talk to each other. It runs on ServerConfig.ListenAddr.
+type PeerServiceServer interface {
+	// Connect is the connection between two peers, one stream each way for as long as they are connected.
+	// The caller sends the first Envelope, see Envelope for the handshake.
+	Connect(PeerService_ConnectServer) error
 	HandleTransaction(context.Context, *Transaction) (*Ack, error)
-	mustEmbedUnimplementedNodeServer()
+	GetSnapshot(context.Context, *SnapshotRequest) (*Snapshot, error)
+	HandleBlock(context.Context, *Block) (*Ack, error)
+	mustEmbedUnimplementedPeerServiceServer()
 }
 
-// UnimplementedNodeServer must be embedded to have forward compatible implementations.
-type UnimplementedNodeServer struct {
+// UnimplementedPeerServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedPeerServiceServer struct {
 }
 
-func (UnimplementedNodeServer) Handshake(context.Context, *Version) (*Version, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Handshake not implemented")
+func (UnimplementedPeerServiceServer) Connect(PeerService_ConnectServer) error {
+	return status.Errorf(codes.Unimplemented, "method Connect not implemented")
 }
-func (UnimplementedNodeServer) HandleTransaction(context.Context, *Transaction) (*Ack, error) {
+func (UnimplementedPeerServiceServer) HandleTransaction(context.Context, *Transaction) (*Ack, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method HandleTransaction not implemented")
 }
-func (UnimplementedNodeServer) mustEmbedUnimplementedNodeServer() {}
+func (UnimplementedPeerServiceServer) GetSnapshot(context.Context, *SnapshotRequest) (*Snapshot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSnapshot not implemented")
+}
+func (UnimplementedPeerServiceServer) HandleBlock(context.Context, *Block) (*Ack, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HandleBlock not implemented")
+}
+func (UnimplementedPeerServiceServer) mustEmbedUnimplementedPeerServiceServer() {}
 
-// UnsafeNodeServer may be embedded to opt out of forward compatibility for this service.
-// Use of this interface is not recommended, as added methods to NodeServer will
+// UnsafePeerServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PeerServiceServer will
 // result in compilation errors.
-type UnsafeNodeServer interface {
-	mustEmbedUnimplementedNodeServer()
+type UnsafePeerServiceServer interface {
+	mustEmbedUnimplementedPeerServiceServer()
+}
+
+func RegisterPeerServiceServer(s grpc.ServiceRegistrar, srv PeerServiceServer) {
+	s.RegisterService(&PeerService_ServiceDesc, srv)
 }
 
-func RegisterNodeServer(s grpc.ServiceRegistrar, srv NodeServer) {
-	s.RegisterService(&Node_ServiceDesc, srv)
+func _PeerService_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PeerServiceServer).Connect(&peerServiceConnectServer{ServerStream: stream})
 }
 
-func _Node_Handshake_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Version)
+type PeerService_ConnectServer interface {
+	Send(*Envelope) error
+	Recv() (*Envelope, error)
+	grpc.ServerStream
+}
+
+type peerServiceConnectServer struct {
+	grpc.ServerStream
+}
+
+func (x *peerServiceConnectServer) Send(m *Envelope) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *peerServiceConnectServer) Recv() (*Envelope, error) {
+	m := new(Envelope)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _PeerService_HandleTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Transaction)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(NodeServer).Handshake(ctx, in)
+		return srv.(PeerServiceServer).HandleTransaction(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: Node_Handshake_FullMethodName,
+		FullMethod: PeerService_HandleTransaction_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(NodeServer).Handshake(ctx, req.(*Version))
+		return srv.(PeerServiceServer).HandleTransaction(ctx, req.(*Transaction))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Node_HandleTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _PeerService_GetSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServiceServer).GetSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PeerService_GetSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServiceServer).GetSnapshot(ctx, req.(*SnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PeerService_HandleBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Block)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServiceServer).HandleBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PeerService_HandleBlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServiceServer).HandleBlock(ctx, req.(*Block))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PeerService_ServiceDesc is the grpc.ServiceDesc for PeerService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PeerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "PeerService",
+	HandlerType: (*PeerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "HandleTransaction",
+			Handler:    _PeerService_HandleTransaction_Handler,
+		},
+		{
+			MethodName: "GetSnapshot",
+			Handler:    _PeerService_GetSnapshot_Handler,
+		},
+		{
+			MethodName: "HandleBlock",
+			Handler:    _PeerService_HandleBlock_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Connect",
+			Handler:       _PeerService_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "proto/types.proto",
+}
+
+const (
+	PublicAPI_SubmitTransaction_FullMethodName = "/PublicAPI/SubmitTransaction"
+	PublicAPI_GetTxProof_FullMethodName        = "/PublicAPI/GetTxProof"
+	PublicAPI_GetTransaction_FullMethodName    = "/PublicAPI/GetTransaction"
+	PublicAPI_GetAddressHistory_FullMethodName = "/PublicAPI/GetAddressHistory"
+	PublicAPI_GetBlock_FullMethodName          = "/PublicAPI/GetBlock"
+	PublicAPI_GetBalance_FullMethodName        = "/PublicAPI/GetBalance"
+	PublicAPI_GetUTXOs_FullMethodName          = "/PublicAPI/GetUTXOs"
+	PublicAPI_GetPeers_FullMethodName          = "/PublicAPI/GetPeers"
+	PublicAPI_GetAnchor_FullMethodName         = "/PublicAPI/GetAnchor"
+	PublicAPI_Subscribe_FullMethodName         = "/PublicAPI/Subscribe"
+)
+
+// PublicAPIClient is the client API for PublicAPI service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// PublicAPI is for wallets and other clients. It runs on its own listener (ServerConfig.PublicListenAddr)
+// with its own token and rate limit, so clients never have to pretend to be a peer.
+type PublicAPIClient interface {
+	SubmitTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Ack, error)
+	GetTxProof(ctx context.Context, in *TxProofRequest, opts ...grpc.CallOption) (*TxProof, error)
+	GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*TransactionStatus, error)
+	GetAddressHistory(ctx context.Context, in *AddressHistoryRequest, opts ...grpc.CallOption) (*AddressHistory, error)
+	GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Block, error)
+	GetBalance(ctx context.Context, in *BalanceRequest, opts ...grpc.CallOption) (*Balance, error)
+	GetUTXOs(ctx context.Context, in *UTXORequest, opts ...grpc.CallOption) (*UTXOList, error)
+	GetPeers(ctx context.Context, in *PeersRequest, opts ...grpc.CallOption) (*PeerList, error)
+	GetAnchor(ctx context.Context, in *AnchorRequest, opts ...grpc.CallOption) (*Anchor, error)
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (PublicAPI_SubscribeClient, error)
+}
+
+type publicAPIClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPublicAPIClient(cc grpc.ClientConnInterface) PublicAPIClient {
+	return &publicAPIClient{cc}
+}
+
+func (c *publicAPIClient) SubmitTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Ack, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Ack)
+	err := c.cc.Invoke(ctx, PublicAPI_SubmitTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *publicAPIClient) GetTxProof(ctx context.Context, in *TxProofRequest, opts ...grpc.CallOption) (*TxProof, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TxProof)
+	err := c.cc.Invoke(ctx, PublicAPI_GetTxProof_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *publicAPIClient) GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*TransactionStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransactionStatus)
+	err := c.cc.Invoke(ctx, PublicAPI_GetTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *publicAPIClient) GetAddressHistory(ctx context.Context, in *AddressHistoryRequest, opts ...grpc.CallOption) (*AddressHistory, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddressHistory)
+	err := c.cc.Invoke(ctx, PublicAPI_GetAddressHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *publicAPIClient) GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Block, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Block)
+	err := c.cc.Invoke(ctx, PublicAPI_GetBlock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *publicAPIClient) GetBalance(ctx context.Context, in *BalanceRequest, opts ...grpc.CallOption) (*Balance, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Balance)
+	err := c.cc.Invoke(ctx, PublicAPI_GetBalance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *publicAPIClient) GetUTXOs(ctx context.Context, in *UTXORequest, opts ...grpc.CallOption) (*UTXOList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UTXOList)
+	err := c.cc.Invoke(ctx, PublicAPI_GetUTXOs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *publicAPIClient) GetPeers(ctx context.Context, in *PeersRequest, opts ...grpc.CallOption) (*PeerList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PeerList)
+	err := c.cc.Invoke(ctx, PublicAPI_GetPeers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *publicAPIClient) GetAnchor(ctx context.Context, in *AnchorRequest, opts ...grpc.CallOption) (*Anchor, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Anchor)
+	err := c.cc.Invoke(ctx, PublicAPI_GetAnchor_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *publicAPIClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (PublicAPI_SubscribeClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PublicAPI_ServiceDesc.Streams[0], PublicAPI_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &publicAPISubscribeClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PublicAPI_SubscribeClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type publicAPISubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *publicAPISubscribeClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PublicAPIServer is the server API for PublicAPI service.
+// All implementations must embed UnimplementedPublicAPIServer
+// for forward compatibility
+//
+// PublicAPI is for wallets and other clients. It runs on its own listener (ServerConfig.PublicListenAddr)
+// with its own token and rate limit, so clients never have to pretend to be a peer.
+type PublicAPIServer interface {
+	SubmitTransaction(context.Context, *Transaction) (*Ack, error)
+	GetTxProof(context.Context, *TxProofRequest) (*TxProof, error)
+	GetTransaction(context.Context, *GetTransactionRequest) (*TransactionStatus, error)
+	GetAddressHistory(context.Context, *AddressHistoryRequest) (*AddressHistory, error)
+	GetBlock(context.Context, *GetBlockRequest) (*Block, error)
+	GetBalance(context.Context, *BalanceRequest) (*Balance, error)
+	GetUTXOs(context.Context, *UTXORequest) (*UTXOList, error)
+	GetPeers(context.Context, *PeersRequest) (*PeerList, error)
+	GetAnchor(context.Context, *AnchorRequest) (*Anchor, error)
+	Subscribe(*SubscribeRequest, PublicAPI_SubscribeServer) error
+	mustEmbedUnimplementedPublicAPIServer()
+}
+
+// UnimplementedPublicAPIServer must be embedded to have forward compatible implementations.
+type UnimplementedPublicAPIServer struct {
+}
+
+func (UnimplementedPublicAPIServer) SubmitTransaction(context.Context, *Transaction) (*Ack, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitTransaction not implemented")
+}
+func (UnimplementedPublicAPIServer) GetTxProof(context.Context, *TxProofRequest) (*TxProof, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTxProof not implemented")
+}
+func (UnimplementedPublicAPIServer) GetTransaction(context.Context, *GetTransactionRequest) (*TransactionStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransaction not implemented")
+}
+func (UnimplementedPublicAPIServer) GetAddressHistory(context.Context, *AddressHistoryRequest) (*AddressHistory, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAddressHistory not implemented")
+}
+func (UnimplementedPublicAPIServer) GetBlock(context.Context, *GetBlockRequest) (*Block, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlock not implemented")
+}
+func (UnimplementedPublicAPIServer) GetBalance(context.Context, *BalanceRequest) (*Balance, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
+}
+func (UnimplementedPublicAPIServer) GetUTXOs(context.Context, *UTXORequest) (*UTXOList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUTXOs not implemented")
+}
+func (UnimplementedPublicAPIServer) GetPeers(context.Context, *PeersRequest) (*PeerList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPeers not implemented")
+}
+func (UnimplementedPublicAPIServer) GetAnchor(context.Context, *AnchorRequest) (*Anchor, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAnchor not implemented")
+}
+func (UnimplementedPublicAPIServer) Subscribe(*SubscribeRequest, PublicAPI_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedPublicAPIServer) mustEmbedUnimplementedPublicAPIServer() {}
+
+// UnsafePublicAPIServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PublicAPIServer will
+// result in compilation errors.
+type UnsafePublicAPIServer interface {
+	mustEmbedUnimplementedPublicAPIServer()
+}
+
+func RegisterPublicAPIServer(s grpc.ServiceRegistrar, srv PublicAPIServer) {
+	s.RegisterService(&PublicAPI_ServiceDesc, srv)
+}
+
+func _PublicAPI_SubmitTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Transaction)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(NodeServer).HandleTransaction(ctx, in)
+		return srv.(PublicAPIServer).SubmitTransaction(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: Node_HandleTransaction_FullMethodName,
+		FullMethod: PublicAPI_SubmitTransaction_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(NodeServer).HandleTransaction(ctx, req.(*Transaction))
+		return srv.(PublicAPIServer).SubmitTransaction(ctx, req.(*Transaction))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-// Node_ServiceDesc is the grpc.ServiceDesc for Node service.
+func _PublicAPI_GetTxProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TxProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PublicAPIServer).GetTxProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PublicAPI_GetTxProof_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PublicAPIServer).GetTxProof(ctx, req.(*TxProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PublicAPI_GetTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PublicAPIServer).GetTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PublicAPI_GetTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PublicAPIServer).GetTransaction(ctx, req.(*GetTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PublicAPI_GetAddressHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddressHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PublicAPIServer).GetAddressHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PublicAPI_GetAddressHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PublicAPIServer).GetAddressHistory(ctx, req.(*AddressHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PublicAPI_GetBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PublicAPIServer).GetBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PublicAPI_GetBlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PublicAPIServer).GetBlock(ctx, req.(*GetBlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PublicAPI_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PublicAPIServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PublicAPI_GetBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PublicAPIServer).GetBalance(ctx, req.(*BalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PublicAPI_GetUTXOs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UTXORequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PublicAPIServer).GetUTXOs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PublicAPI_GetUTXOs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PublicAPIServer).GetUTXOs(ctx, req.(*UTXORequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PublicAPI_GetPeers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PeersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PublicAPIServer).GetPeers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PublicAPI_GetPeers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PublicAPIServer).GetPeers(ctx, req.(*PeersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PublicAPI_GetAnchor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnchorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PublicAPIServer).GetAnchor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PublicAPI_GetAnchor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PublicAPIServer).GetAnchor(ctx, req.(*AnchorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PublicAPI_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PublicAPIServer).Subscribe(m, &publicAPISubscribeServer{ServerStream: stream})
+}
+
+type PublicAPI_SubscribeServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type publicAPISubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *publicAPISubscribeServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// PublicAPI_ServiceDesc is the grpc.ServiceDesc for PublicAPI service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
-var Node_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "Node",
-	HandlerType: (*NodeServer)(nil),
+var PublicAPI_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "PublicAPI",
+	HandlerType: (*PublicAPIServer)(nil),
 	Methods: []grpc.MethodDesc{
 		{
-			MethodName: "Handshake",
-			Handler:    _Node_Handshake_Handler,
+			MethodName: "SubmitTransaction",
+			Handler:    _PublicAPI_SubmitTransaction_Handler,
 		},
 		{
-			MethodName: "HandleTransaction",
-			Handler:    _Node_HandleTransaction_Handler,
+			MethodName: "GetTxProof",
+			Handler:    _PublicAPI_GetTxProof_Handler,
+		},
+		{
+			MethodName: "GetTransaction",
+			Handler:    _PublicAPI_GetTransaction_Handler,
+		},
+		{
+			MethodName: "GetAddressHistory",
+			Handler:    _PublicAPI_GetAddressHistory_Handler,
+		},
+		{
+			MethodName: "GetBlock",
+			Handler:    _PublicAPI_GetBlock_Handler,
+		},
+		{
+			MethodName: "GetBalance",
+			Handler:    _PublicAPI_GetBalance_Handler,
+		},
+		{
+			MethodName: "GetUTXOs",
+			Handler:    _PublicAPI_GetUTXOs_Handler,
+		},
+		{
+			MethodName: "GetPeers",
+			Handler:    _PublicAPI_GetPeers_Handler,
+		},
+		{
+			MethodName: "GetAnchor",
+			Handler:    _PublicAPI_GetAnchor_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _PublicAPI_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/types.proto",
+}
+
+const (
+	AdminAPI_ListBans_FullMethodName = "/AdminAPI/ListBans"
+	AdminAPI_Ban_FullMethodName      = "/AdminAPI/Ban"
+	AdminAPI_Unban_FullMethodName    = "/AdminAPI/Unban"
+)
+
+// AdminAPIClient is the client API for AdminAPI service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AdminAPI is for the operator of the node. It runs on ServerConfig.AdminListenAddr, keep that on localhost.
+type AdminAPIClient interface {
+	ListBans(ctx context.Context, in *ListBansRequest, opts ...grpc.CallOption) (*BanList, error)
+	Ban(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*Ack, error)
+	Unban(ctx context.Context, in *UnbanRequest, opts ...grpc.CallOption) (*Ack, error)
+}
+
+type adminAPIClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminAPIClient(cc grpc.ClientConnInterface) AdminAPIClient {
+	return &adminAPIClient{cc}
+}
+
+func (c *adminAPIClient) ListBans(ctx context.Context, in *ListBansRequest, opts ...grpc.CallOption) (*BanList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BanList)
+	err := c.cc.Invoke(ctx, AdminAPI_ListBans_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminAPIClient) Ban(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*Ack, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Ack)
+	err := c.cc.Invoke(ctx, AdminAPI_Ban_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminAPIClient) Unban(ctx context.Context, in *UnbanRequest, opts ...grpc.CallOption) (*Ack, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Ack)
+	err := c.cc.Invoke(ctx, AdminAPI_Unban_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminAPIServer is the server API for AdminAPI service.
+// All implementations must embed UnimplementedAdminAPIServer
+// for forward compatibility
+//
+// AdminAPI is for the operator of the node. It runs on ServerConfig.AdminListenAddr, keep that on localhost.
+type AdminAPIServer interface {
+	ListBans(context.Context, *ListBansRequest) (*BanList, error)
+	Ban(context.Context, *BanRequest) (*Ack, error)
+	Unban(context.Context, *UnbanRequest) (*Ack, error)
+	mustEmbedUnimplementedAdminAPIServer()
+}
+
+// UnimplementedAdminAPIServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminAPIServer struct {
+}
+
+func (UnimplementedAdminAPIServer) ListBans(context.Context, *ListBansRequest) (*BanList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBans not implemented")
+}
+func (UnimplementedAdminAPIServer) Ban(context.Context, *BanRequest) (*Ack, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ban not implemented")
+}
+func (UnimplementedAdminAPIServer) Unban(context.Context, *UnbanRequest) (*Ack, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unban not implemented")
+}
+func (UnimplementedAdminAPIServer) mustEmbedUnimplementedAdminAPIServer() {}
+
+// UnsafeAdminAPIServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminAPIServer will
+// result in compilation errors.
+type UnsafeAdminAPIServer interface {
+	mustEmbedUnimplementedAdminAPIServer()
+}
+
+func RegisterAdminAPIServer(s grpc.ServiceRegistrar, srv AdminAPIServer) {
+	s.RegisterService(&AdminAPI_ServiceDesc, srv)
+}
+
+func _AdminAPI_ListBans_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBansRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminAPIServer).ListBans(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminAPI_ListBans_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminAPIServer).ListBans(ctx, req.(*ListBansRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminAPI_Ban_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminAPIServer).Ban(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminAPI_Ban_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminAPIServer).Ban(ctx, req.(*BanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminAPI_Unban_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnbanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminAPIServer).Unban(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminAPI_Unban_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminAPIServer).Unban(ctx, req.(*UnbanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminAPI_ServiceDesc is the grpc.ServiceDesc for AdminAPI service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminAPI_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "AdminAPI",
+	HandlerType: (*AdminAPIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListBans",
+			Handler:    _AdminAPI_ListBans_Handler,
+		},
+		{
+			MethodName: "Ban",
+			Handler:    _AdminAPI_Ban_Handler,
+		},
+		{
+			MethodName: "Unban",
+			Handler:    _AdminAPI_Unban_Handler,
 		},
 	},
 	Streams:  []grpc.StreamDesc{},
@@ -0,0 +1,61 @@
+package types
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+)
+
+const AssetIDLen = 32
+
+// AssetID returns the ID of the asset called name issued by pubKey.
+// Because the public key is part of the ID, nobody else can mint the same asset.
+func AssetID(pubKey *crypto.PublicKey, name string) []byte {
+	h := sha256.New()
+	h.Write(pubKey.Bytes())
+	h.Write([]byte(name))
+	return h.Sum(nil)
+}
+
+// IssuedAssetID returns the ID of the asset minted by the transaction, or nil
+// if the transaction is not an issuance.
+func IssuedAssetID(tx *proto.Transaction) []byte {
+	if tx.Issuance == nil {
+		return nil
+	}
+	pubKey, err := crypto.PublicKeyFromBytes(tx.Issuance.PublicKey)
+	if err != nil {
+		return nil
+	}
+	return AssetID(pubKey, tx.Issuance.Name)
+}
+
+// SignIssuance signs the issuance of the transaction with the key of the issuer.
+// Sign the inputs and the issuance in any order, they all sign the same hash.
+func SignIssuance(pk *crypto.PrivateKey, tx *proto.Transaction) *crypto.Signature {
+	tx.Issuance.PublicKey = pk.Public().Bytes()
+	sig := pk.Sign(hashTransactionForSigning(tx))
+	tx.Issuance.Signature = sig.Bytes()
+	return sig
+}
+
+func VerifyIssuance(tx *proto.Transaction) error {
+	if tx.Issuance == nil {
+		return errors.New("not an issuance")
+	}
+	pubKey, err := crypto.PublicKeyFromBytes(tx.Issuance.PublicKey)
+	if err != nil {
+		return fmt.Errorf("issuance: %w", err)
+	}
+	sig, err := crypto.SignatureFromBytes(tx.Issuance.Signature)
+	if err != nil {
+		return fmt.Errorf("issuance: %w", err)
+	}
+	if !sig.Verify(pubKey, hashTransactionForSigning(tx)) {
+		return errors.New("invalid issuance signature")
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssetID(t *testing.T) {
+	issuer := crypto.GeneratePrivateKey().Public()
+	assert.Equal(t, AssetIDLen, len(AssetID(issuer, "POINTS")))
+	assert.Equal(t, AssetID(issuer, "POINTS"), AssetID(issuer, "POINTS"))
+	assert.NotEqual(t, AssetID(issuer, "POINTS"), AssetID(issuer, "USD"))
+	assert.NotEqual(t, AssetID(issuer, "POINTS"), AssetID(crypto.GeneratePrivateKey().Public(), "POINTS"))
+}
+
+func TestSignVerifyIssuance(t *testing.T) {
+	var (
+		fromPrivKey = crypto.GeneratePrivateKey()
+		issuer      = crypto.GeneratePrivateKey()
+	)
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash: util.RandomHash(),
+				PublicKey:  fromPrivKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  100,
+				Address: fromPrivKey.Public().Address().Bytes(),
+				Asset:   AssetID(issuer.Public(), "POINTS"),
+			},
+		},
+		Issuance: &proto.AssetIssuance{
+			Name: "POINTS",
+		},
+	}
+	// The issuer signs first, the input second. Both sign the same hash.
+	SignIssuance(issuer, tx)
+	tx.Inputs[0].Signature = SignTransaction(fromPrivKey, tx).Bytes()
+
+	assert.Nil(t, VerifyTransaction(tx))
+	assert.Equal(t, AssetID(issuer.Public(), "POINTS"), IssuedAssetID(tx))
+
+	tx.Issuance.Name = "USD"
+	assert.NotNil(t, VerifyTransaction(tx))
+}
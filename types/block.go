@@ -8,28 +8,10 @@ import (
 
 	"github.com/Fito305/blocker/crypto"
 	"github.com/Fito305/blocker/proto"
-	"github.com/cbergoon/merkletree"
 	// pb "google.golang.org/protobuf/runtime/protoimpl"
 	pb "github.com/golang/protobuf/proto"
 )
 
-type TxHash struct {
-	hash []byte
-}
-
-func NewTxHash(hash []byte) TxHash {
-	return TxHash{hash: hash}
-}
-
-func (h TxHash) CalculateHash() ([]byte, error) {
-	return h.hash, nil
-}
-
-func (h TxHash) Equals(other merkletree.Content) (bool, error) {
-	equals := bytes.Equal(h.hash, other.(TxHash).hash)
-	return equals, nil
-}
-
 func VerifyBlock(b *proto.Block) bool { // Normally we can attach these functions on a receiver (form the block receiver), but in this case we cannot because it is on a generated proto buffer. But it actually the same concept.
 	if len(b.Transactions) > 0 {
 		if !VerifyRootHash(b) {
@@ -38,19 +20,17 @@ func VerifyBlock(b *proto.Block) bool { // Normally we can attach these function
 		}
 	}
 
-	if len(b.PublicKey) != crypto.PubKeyLen {
+	pubKey, err := crypto.PublicKeyFromBytes(b.PublicKey)
+	if err != nil {
 		fmt.Println("Invalid public key length")
 		return false
 	}
-	if len(b.Signature) != crypto.SignatureLen {
+	sig, err := crypto.SignatureFromBytes(b.Signature)
+	if err != nil {
 		fmt.Println("Invalid signature length")
 		return false
 	}
-	var (
-		sig    = crypto.SignatureFromBytes(b.Signature)
-		pubKey = crypto.PublicKeyFromBytes(b.PublicKey)
-		hash   = HashBlock(b)
-	)
+	hash := HashBlock(b)
 	fmt.Println(hex.EncodeToString(hash))
 	if !sig.Verify(pubKey, hash) {
 		fmt.Printf("%v\n", b.Header) // delete all the logs
@@ -63,12 +43,7 @@ func VerifyBlock(b *proto.Block) bool { // Normally we can attach these function
 
 func SignBlock(pk *crypto.PrivateKey, b *proto.Block) *crypto.Signature {
 	if len(b.Transactions) > 0 {
-		tree, err := GetMerkleTree(b)
-		if err != nil {
-			panic(err)
-		}
-
-		b.Header.RootHash = tree.MerkleRoot()
+		b.Header.RootHash = CalculateRootHash(b)
 	}
 	hash := HashBlock(b)
 	fmt.Println("Hash before signature", hex.EncodeToString(hash))
@@ -80,35 +55,42 @@ func SignBlock(pk *crypto.PrivateKey, b *proto.Block) *crypto.Signature {
 }
 
 func VerifyRootHash(b *proto.Block) bool {
-	// We are going to verify the block, if it is verified, and if we don't have the root hash we are going to set it.
-	tree, err := GetMerkleTree(b)
-	if err != nil {
-		return false
-	}
-	valid, err := tree.VerifyTree()
-	if err != nil {
-		return false
-	}
-
-	if !valid {
-		return false
-	}
+	// We are going to construct our own Merkle tree from the transaction hashes and compare its root with the one in the header.
+	return bytes.Equal(b.Header.RootHash, CalculateRootHash(b))
+}
 
-	return bytes.Equal(b.Header.RootHash, tree.MerkleRoot())
+// CalculateRootHash returns the Merkle root of the transaction hashes of the block (see merkle.go).
+func CalculateRootHash(b *proto.Block) []byte {
+	return MerkleRoot(txHashes(b))
 }
 
-func GetMerkleTree(b *proto.Block) (*merkletree.MerkleTree, error) {
-	list := make([]merkletree.Content, len(b.Transactions))
-	for i := 0; i < len(b.Transactions); i++ {
-		list[i] = NewTxHash(HashTransaction(b.Transactions[i]))
+// GetMerkleProof returns the Merkle branch proving the transaction with the
+// given hash is part of the root hash of the block.
+func GetMerkleProof(b *proto.Block, txHash []byte) (*proto.MerkleProof, error) {
+	hashes := txHashes(b)
+	for i, hash := range hashes {
+		if bytes.Equal(hash, txHash) {
+			return BuildMerkleProof(hashes, i)
+		}
 	}
+	return nil, fmt.Errorf("tx [%s] is not in the block", hex.EncodeToString(txHash))
+}
 
-	t, err := merkletree.NewTree(list)
-	if err != nil {
-		return nil, err
+// VerifyTxProof checks that the transaction of the proof is under the root hash
+// of its header. The caller still has to check the header against a block hash it trusts.
+func VerifyTxProof(p *proto.TxProof) bool {
+	if p.Header == nil {
+		return false
 	}
+	return VerifyMerkleProof(p.Header.RootHash, p.TxHash, p.Proof)
+}
 
-	return t, nil
+func txHashes(b *proto.Block) [][]byte {
+	hashes := make([][]byte, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		hashes[i] = HashTransaction(tx)
+	}
+	return hashes
 }
 
 // HashBlock returns a SHA256 of the header.
@@ -60,3 +60,27 @@ func TestHashBlock(t *testing.T) {
 	// fmt.Println(hex.EncodeToString(hash)) // Will print out the hash of the block. That is what we are going to use to retrieve the block. You can also retireve a block by it's height, but you can also query a block by it's hash on the chain. And that is basically this hash.
 	assert.Equal(t, 32, len(hash))
 }
+
+func TestMerkleProof(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	for n := 1; n <= 9; n++ {
+		block := util.RandomBlock()
+		for i := 0; i < n; i++ {
+			block.Transactions = append(block.Transactions, &proto.Transaction{
+				Version: int32(i),
+			})
+		}
+		SignBlock(privKey, block)
+
+		for _, tx := range block.Transactions {
+			hash := HashTransaction(tx)
+			proof, err := GetMerkleProof(block, hash)
+			assert.Nil(t, err)
+			assert.True(t, VerifyMerkleProof(block.Header.RootHash, hash, proof))
+			assert.False(t, VerifyMerkleProof(block.Header.RootHash, util.RandomHash(), proof))
+		}
+
+		_, err := GetMerkleProof(block, util.RandomHash())
+		assert.NotNil(t, err)
+	}
+}
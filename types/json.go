@@ -0,0 +1,177 @@
+package types
+
+import (
+	"encoding/hex"
+
+	"github.com/Fito305/blocker/proto"
+)
+
+// The JSON representations of our proto types. Protobuf's own JSON encoding renders bytes
+// as base64, but everybody reads hashes, keys and addresses as hex, so we do it ourselves.
+
+type HeaderJSON struct {
+	Version   int32  `json:"version"`
+	Height    int32  `json:"height"`
+	PrevHash  string `json:"prevHash"`
+	RootHash  string `json:"rootHash"`
+	StateRoot string `json:"stateRoot"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type BlockJSON struct {
+	Hash         string             `json:"hash"`
+	Header       *HeaderJSON        `json:"header"`
+	Transactions []*TransactionJSON `json:"transactions"`
+	PublicKey    string             `json:"publicKey"`
+	Signature    string             `json:"signature"`
+}
+
+type TxInputJSON struct {
+	PrevTxHash   string `json:"prevTxHash"`
+	PrevOutIndex uint32 `json:"prevOutIndex"`
+	PublicKey    string `json:"publicKey"`
+	Signature    string `json:"signature"`
+}
+
+type TxOutputJSON struct {
+	Amount  uint64 `json:"amount"`
+	Address string `json:"address,omitempty"`
+	Asset   string `json:"asset,omitempty"`
+	Data    string `json:"data,omitempty"`
+}
+
+type AssetIssuanceJSON struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+type TransactionJSON struct {
+	Hash     string             `json:"hash,omitempty"` // Ignored when decoding, we always hash the tx ourselves.
+	Version  int32              `json:"version"`
+	Inputs   []*TxInputJSON     `json:"inputs"`
+	Outputs  []*TxOutputJSON    `json:"outputs"`
+	Issuance *AssetIssuanceJSON `json:"issuance,omitempty"`
+}
+
+func NewHeaderJSON(h *proto.Header) *HeaderJSON {
+	return &HeaderJSON{
+		Version:   h.Version,
+		Height:    h.Height,
+		PrevHash:  hex.EncodeToString(h.PrevHash),
+		RootHash:  hex.EncodeToString(h.RootHash),
+		StateRoot: hex.EncodeToString(h.StateRoot),
+		Timestamp: h.Timestamp,
+	}
+}
+
+func NewBlockJSON(b *proto.Block) *BlockJSON {
+	block := &BlockJSON{
+		Hash:         hex.EncodeToString(HashBlock(b)),
+		Header:       NewHeaderJSON(b.Header),
+		Transactions: make([]*TransactionJSON, len(b.Transactions)),
+		PublicKey:    hex.EncodeToString(b.PublicKey),
+		Signature:    hex.EncodeToString(b.Signature),
+	}
+	for i, tx := range b.Transactions {
+		block.Transactions[i] = NewTransactionJSON(tx)
+	}
+	return block
+}
+
+func NewTransactionJSON(tx *proto.Transaction) *TransactionJSON {
+	t := &TransactionJSON{
+		Hash:    hex.EncodeToString(HashTransaction(tx)),
+		Version: tx.Version,
+		Inputs:  make([]*TxInputJSON, len(tx.Inputs)),
+		Outputs: make([]*TxOutputJSON, len(tx.Outputs)),
+	}
+	for i, input := range tx.Inputs {
+		t.Inputs[i] = &TxInputJSON{
+			PrevTxHash:   hex.EncodeToString(input.PrevTxHash),
+			PrevOutIndex: input.PrevOutIndex,
+			PublicKey:    hex.EncodeToString(input.PublicKey),
+			Signature:    hex.EncodeToString(input.Signature),
+		}
+	}
+	for i, output := range tx.Outputs {
+		t.Outputs[i] = &TxOutputJSON{
+			Amount:  output.Amount,
+			Address: hex.EncodeToString(output.Address),
+			Asset:   hex.EncodeToString(output.Asset),
+			Data:    hex.EncodeToString(output.Data),
+		}
+	}
+	if tx.Issuance != nil {
+		t.Issuance = &AssetIssuanceJSON{
+			Name:      tx.Issuance.Name,
+			PublicKey: hex.EncodeToString(tx.Issuance.PublicKey),
+			Signature: hex.EncodeToString(tx.Issuance.Signature),
+		}
+	}
+	return t
+}
+
+// Proto decodes the JSON transaction back into a proto.Transaction.
+func (t *TransactionJSON) Proto() (*proto.Transaction, error) {
+	tx := &proto.Transaction{
+		Version: t.Version,
+		Inputs:  make([]*proto.TxInput, len(t.Inputs)),
+		Outputs: make([]*proto.TxOutput, len(t.Outputs)),
+	}
+	for i, input := range t.Inputs {
+		in := &proto.TxInput{
+			PrevOutIndex: input.PrevOutIndex,
+		}
+		if err := decodeHex(&in.PrevTxHash, input.PrevTxHash); err != nil {
+			return nil, err
+		}
+		if err := decodeHex(&in.PublicKey, input.PublicKey); err != nil {
+			return nil, err
+		}
+		if err := decodeHex(&in.Signature, input.Signature); err != nil {
+			return nil, err
+		}
+		tx.Inputs[i] = in
+	}
+	for i, output := range t.Outputs {
+		out := &proto.TxOutput{
+			Amount: output.Amount,
+		}
+		if err := decodeHex(&out.Address, output.Address); err != nil {
+			return nil, err
+		}
+		if err := decodeHex(&out.Asset, output.Asset); err != nil {
+			return nil, err
+		}
+		if err := decodeHex(&out.Data, output.Data); err != nil {
+			return nil, err
+		}
+		tx.Outputs[i] = out
+	}
+	if t.Issuance != nil {
+		tx.Issuance = &proto.AssetIssuance{
+			Name: t.Issuance.Name,
+		}
+		if err := decodeHex(&tx.Issuance.PublicKey, t.Issuance.PublicKey); err != nil {
+			return nil, err
+		}
+		if err := decodeHex(&tx.Issuance.Signature, t.Issuance.Signature); err != nil {
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// decodeHex leaves dst nil for an empty string, so the tx hashes the same as before encoding.
+func decodeHex(dst *[]byte, s string) error {
+	if s == "" {
+		return nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*dst = b
+	return nil
+}
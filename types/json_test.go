@@ -0,0 +1,56 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionJSON(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   util.RandomHash(),
+				PrevOutIndex: 3,
+				PublicKey:    privKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  10,
+				Address: privKey.Public().Address().Bytes(),
+				Asset:   AssetID(privKey.Public(), "POINTS"),
+			},
+			{
+				Data: util.RandomHash(),
+			},
+		},
+		Issuance: &proto.AssetIssuance{
+			Name: "POINTS",
+		},
+	}
+	SignIssuance(privKey, tx)
+	tx.Inputs[0].Signature = SignTransaction(privKey, tx).Bytes()
+
+	b, err := json.Marshal(NewTransactionJSON(tx))
+	require.Nil(t, err)
+	var txJSON TransactionJSON
+	require.Nil(t, json.Unmarshal(b, &txJSON))
+	assert.Equal(t, privKey.Public().Address().String(), txJSON.Outputs[0].Address)
+
+	decoded, err := txJSON.Proto()
+	require.Nil(t, err)
+	assert.Equal(t, HashTransaction(tx), HashTransaction(decoded))
+	assert.Nil(t, VerifyTransaction(decoded))
+
+	txJSON.Inputs[0].PublicKey = "zz"
+	_, err = txJSON.Proto()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,123 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/Fito305/blocker/proto"
+)
+
+// Our Merkle tree is the one from RFC 6962 (Certificate Transparency).
+//
+//   - a leaf is hashed as sha256(0x00 || data)
+//   - an inner node is hashed as sha256(0x01 || left || right)
+//   - when a level has an odd number of nodes, the last one is carried up to
+//     the next level as is. We never duplicate it.
+//   - the root of an empty tree is sha256("")
+//
+// The prefixes make sure a leaf can never be passed off as an inner node (second preimage),
+// and not duplicating the odd node makes sure two different lists of transactions can never
+// have the same root (Bitcoin CVE-2012-2459, [A, B, C] and [A, B, C, C] have the same root there).
+const (
+	merkleLeafPrefix byte = 0x00
+	merkleNodePrefix byte = 0x01
+
+	// A proof never has more siblings than the height of the tree, 64 levels is 2^64 leaves.
+	maxMerkleProofLen = 64
+)
+
+func HashMerkleLeaf(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func HashMerkleNode(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// MerkleRoot returns the root of the tree with the given leaves, in order.
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	}
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = HashMerkleLeaf(leaf)
+	}
+	for len(level) > 1 {
+		level = nextMerkleLevel(level)
+	}
+	return level[0]
+}
+
+// BuildMerkleProof returns the branch proving the leaf at index is part of the tree.
+func BuildMerkleProof(leaves [][]byte, index int) (*proto.MerkleProof, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, fmt.Errorf("leaf index (%d) out of range - leaves (%d)", index, len(leaves))
+	}
+	proof := &proto.MerkleProof{
+		Index:  uint64(index),
+		Leaves: uint64(len(leaves)),
+	}
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = HashMerkleLeaf(leaf)
+	}
+	for i := index; len(level) > 1; i /= 2 {
+		if i%2 == 1 {
+			proof.Siblings = append(proof.Siblings, level[i-1])
+		} else if i+1 < len(level) {
+			proof.Siblings = append(proof.Siblings, level[i+1])
+		} // else we are the odd one out and get carried up without a sibling.
+		level = nextMerkleLevel(level)
+	}
+	return proof, nil
+}
+
+// VerifyMerkleProof checks that the leaf data hashes up to root following the proof.
+func VerifyMerkleProof(root, leaf []byte, proof *proto.MerkleProof) bool {
+	if proof == nil || proof.Index >= proof.Leaves || len(proof.Siblings) > maxMerkleProofLen {
+		return false
+	}
+	var (
+		hash     = HashMerkleLeaf(leaf)
+		index    = proof.Index
+		width    = proof.Leaves
+		siblings = proof.Siblings
+	)
+	for ; width > 1; width = (width + 1) / 2 {
+		if index%2 == 1 || index+1 < width {
+			if len(siblings) == 0 {
+				return false
+			}
+			if index%2 == 1 {
+				hash = HashMerkleNode(siblings[0], hash)
+			} else {
+				hash = HashMerkleNode(hash, siblings[0])
+			}
+			siblings = siblings[1:]
+		}
+		index /= 2
+	}
+	return len(siblings) == 0 && bytes.Equal(hash, root)
+}
+
+func nextMerkleLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			continue
+		}
+		next = append(next, HashMerkleNode(level[i], level[i+1]))
+	}
+	return next
+}
@@ -0,0 +1,70 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The leaves of the test vectors are sha256([]byte{i}) for i = 0, 1, 2 ...
+func testLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		hash := sha256.Sum256([]byte{byte(i)})
+		leaves[i] = hash[:]
+	}
+	return leaves
+}
+
+func TestMerkleRootVectors(t *testing.T) {
+	vectors := []string{
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"d9de27625445003d8a9739a851e3ff8d41c0683630b4d63a88327a6aaa37c409",
+		"604d540f09268b91672ab011394d5266ccd7d4484d0d109411a55848126a1b2c",
+		"d1f13800048f5909d4043fc0c152f6643280cba608b672715e56ce159a20629f",
+		"0dcc2b645c00dfa2338e1c7ac2c4b570beda5a476d58836e55e28bde55e6bee1",
+		"6b313b611b40676b9e1dfd70c4503f2379f88f0f1c2740fb7e1cacc32c113465",
+		"33d501ae67e6e5745c0aa49e3aff95130969b568208b07210c508caa5ff39b3c",
+		"bee2275db16667589a4515f63e0d053a2fa602c1d9f9703e98920a5bdad59baf",
+	}
+	for n, root := range vectors {
+		assert.Equal(t, root, hex.EncodeToString(MerkleRoot(testLeaves(n))), "leaves: %d", n)
+	}
+}
+
+// [A, B, C] and [A, B, C, C] must never have the same root (CVE-2012-2459).
+func TestMerkleRootNoDuplicateMutation(t *testing.T) {
+	leaves := testLeaves(3)
+	mutated := append(testLeaves(3), leaves[2])
+	assert.NotEqual(t, MerkleRoot(leaves), MerkleRoot(mutated))
+
+	// An inner node can not be passed off as a leaf.
+	inner := append(HashMerkleLeaf(leaves[0]), HashMerkleLeaf(leaves[1])...)
+	assert.NotEqual(t, MerkleRoot(testLeaves(2)), MerkleRoot([][]byte{inner}))
+}
+
+func TestBuildVerifyMerkleProof(t *testing.T) {
+	for n := 1; n <= 33; n++ {
+		leaves := testLeaves(n)
+		root := MerkleRoot(leaves)
+		for i := range leaves {
+			proof, err := BuildMerkleProof(leaves, i)
+			require.Nil(t, err)
+			assert.True(t, VerifyMerkleProof(root, leaves[i], proof), "leaves: %d index: %d", n, i)
+
+			if n == 1 {
+				continue
+			}
+			// The same branch does not prove another leaf or another position.
+			assert.False(t, VerifyMerkleProof(root, leaves[(i+1)%n], proof))
+			proof.Index = uint64((i + 1) % n)
+			assert.False(t, VerifyMerkleProof(root, leaves[i], proof))
+		}
+	}
+
+	_, err := BuildMerkleProof(testLeaves(3), 3)
+	assert.NotNil(t, err)
+}
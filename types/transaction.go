@@ -2,6 +2,7 @@ package types
 
 import (
 	"crypto/sha256"
+	"fmt"
 
 
 	"github.com/Fito305/blocker/crypto"
@@ -10,8 +11,23 @@ import (
 	pb "github.com/golang/protobuf/proto"
 )
 
+// MaxDataLen is the maximum size of the data an output can anchor on chain.
+// Enough for a couple of hashes, we don't want people storing files in our blocks.
+const MaxDataLen = 80
+
+// IsDataOutput reports whether the output is an unspendable data carrier.
+func IsDataOutput(output *proto.TxOutput) bool {
+	return len(output.Data) > 0
+}
+
+// HashData returns the content hash we use to index anchored data.
+func HashData(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
 func SignTransaction(pk *crypto.PrivateKey, tx *proto.Transaction) *crypto.Signature {
-	return pk.Sign(HashTransaction(tx))
+	return pk.Sign(hashTransactionForSigning(tx))
 }
 
 func HashTransaction(tx *proto.Transaction) []byte {
@@ -23,27 +39,41 @@ func HashTransaction(tx *proto.Transaction) []byte {
 	return hash[:] // Specify it as a slice.
 }
 
-func VerifyTransaction(tx *proto.Transaction) bool {
-	for _, input := range tx.Inputs {
-		if len(input.Signature) == 0 {
-			panic("the transaction has no signature")
-		}
+// hashTransactionForSigning hashes a copy of the transaction with every
+// signature (inputs and issuance) stripped. That way each signer signs the
+// same message, no matter in what order the signatures are attached.
+func hashTransactionForSigning(tx *proto.Transaction) []byte {
+	txCopy := pb.Clone(tx).(*proto.Transaction)
+	for _, input := range txCopy.Inputs {
+		input.Signature = nil
+	}
+	if txCopy.Issuance != nil {
+		txCopy.Issuance.Signature = nil
+	}
+	return HashTransaction(txCopy)
+}
 
-		var (
-			sig = crypto.SignatureFromBytes(input.Signature)
-			pubKey = crypto.PublicKeyFromBytes(input.PublicKey)
-		)
-		// TODO: make sure we dont run into problems after verification
-		// cause we have set the signature to nil.
-		tempSig := input.Signature
-		input.Signature = nil // We don't hash the signature, we hash the transaction without the signature.
-		// That is why we set the signature to nil. And then we are going to hash the transaction and then verify.
-		if !sig.Verify(pubKey, HashTransaction(tx)) {
-			return false
+// VerifyTransaction checks the signature of every input and of the issuance. Transactions come from anybody,
+// a malformed key or signature is an error like a wrong one.
+func VerifyTransaction(tx *proto.Transaction) error {
+	hash := hashTransactionForSigning(tx) // We don't hash the signatures, so we hash a copy of the transaction without them.
+	for i, input := range tx.Inputs {
+		pubKey, err := crypto.PublicKeyFromBytes(input.PublicKey)
+		if err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
 		}
-		input.Signature = tempSig
+		sig, err := crypto.SignatureFromBytes(input.Signature)
+		if err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
+		}
+		if !sig.Verify(pubKey, hash) {
+			return fmt.Errorf("input %d: invalid signature", i)
+		}
+	}
+	if tx.Issuance != nil {
+		return VerifyIssuance(tx)
 	}
-	return true
+	return nil
 }
 
 
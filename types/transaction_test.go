@@ -18,9 +18,9 @@ func TestNewTransaction(t *testing.T) {
 	toAddress := toPrivKey.Public().Address().Bytes()
 
 	input := &proto.TxInput{
-		PrevTxHas:    util.RandomHash(),
+		PrevTxHash:   util.RandomHash(),
 		PrevOutIndex: 0,
-		PublicKey:    fromPrivKey.Public.Bytes(),
+		PublicKey:    fromPrivKey.Public().Bytes(),
 	}
 
 	output1 := &proto.TxOutput{
@@ -40,7 +40,7 @@ func TestNewTransaction(t *testing.T) {
 	sig := SignTransaction(fromPrivKey, tx) // We send it, we need to sign it.
 	input.Signature = sig.Bytes()
 
-	assert.True(t, VerifyTransaction(tx))
+	assert.Nil(t, VerifyTransaction(tx))
 
 	fmt.Printf("%+v\n", tx)
 
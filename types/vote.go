@@ -0,0 +1,44 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+)
+
+// hashVote is what a validator signs when it votes. It's a hash of its own, so a vote never
+// passes for the signature of a block.
+func hashVote(v *proto.Vote) []byte {
+	h := sha256.New()
+	h.Write([]byte("vote"))
+	h.Write(v.BlockHash)
+	binary.Write(h, binary.BigEndian, v.Height)
+	return h.Sum(nil)
+}
+
+// SignVote signs the vote with the key of the validator.
+func SignVote(pk *crypto.PrivateKey, v *proto.Vote) *crypto.Signature {
+	v.PublicKey = pk.Public().Bytes()
+	sig := pk.Sign(hashVote(v))
+	v.Signature = sig.Bytes()
+	return sig
+}
+
+func VerifyVote(v *proto.Vote) error {
+	pubKey, err := crypto.PublicKeyFromBytes(v.PublicKey)
+	if err != nil {
+		return fmt.Errorf("vote: %w", err)
+	}
+	sig, err := crypto.SignatureFromBytes(v.Signature)
+	if err != nil {
+		return fmt.Errorf("vote: %w", err)
+	}
+	if !sig.Verify(pubKey, hashVote(v)) {
+		return errors.New("invalid vote signature")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/Fito305/blocker/crypto"
+	"github.com/Fito305/blocker/proto"
+	"github.com/Fito305/blocker/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignVerifyVote(t *testing.T) {
+	var (
+		validator = crypto.GeneratePrivateKey()
+		block     = util.RandomBlock()
+		vote      = &proto.Vote{BlockHash: HashBlock(block), Height: 1}
+	)
+	SignVote(validator, vote)
+	assert.Nil(t, VerifyVote(vote))
+
+	vote.Height = 2
+	assert.NotNil(t, VerifyVote(vote))
+	vote.Height = 1
+
+	// A vote is not a block signature, nor the other way around.
+	SignBlock(validator, block)
+	assert.NotEqual(t, block.Signature, vote.Signature)
+	vote.Signature = block.Signature
+	assert.NotNil(t, VerifyVote(vote))
+
+	vote.PublicKey = []byte{1, 2, 3}
+	assert.NotNil(t, VerifyVote(vote))
+}